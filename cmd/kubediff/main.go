@@ -1,17 +1,36 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	command "github.com/eduardodbr/kubediff/internal/command"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
+	// stdout is reserved for the results so they can be consumed by other tools
+	log.SetOutput(os.Stderr)
+	// fatal errors are execution errors, exit code 1 is reserved for differences
+	log.StandardLogger().ExitFunc = func(int) { os.Exit(command.ExitError) }
+
 	rootCmd := command.Newkubediff()
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.SilenceErrors = true
 	rootCmd.AddCommand(command.NewImages())
 	rootCmd.AddCommand(command.NewEnvs())
+	rootCmd.AddCommand(command.NewConfigMaps())
+	rootCmd.AddCommand(command.NewSecrets())
+	rootCmd.AddCommand(command.NewResources())
+	rootCmd.AddCommand(command.NewReplicas())
+	rootCmd.AddCommand(command.NewProbes())
+	rootCmd.AddCommand(command.NewSnapshot())
 
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+		if errors.Is(err, command.ErrDrift) {
+			os.Exit(command.ExitDrift)
+		}
+		log.Error(err)
+		os.Exit(command.ExitError)
 	}
 }
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigMaps() *cobra.Command {
+	kd := &kubediff{
+		resources: []string{"configmap"},
+	}
+	command := &cobra.Command{
+		Use:   "configmaps",
+		Short: "Detect different configmap keys and values between Kubernetes clusters",
+		Long: `A CLI tool to detect added, removed and changed keys in the data and binaryData of configmaps with the same
+		name in Kubernetes clusters`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
+			}
+			if err := validateIgnoreKeys(kd.ignoreKeys); err != nil {
+				return err
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer("data", "binaryData")
+			if err != nil {
+				return err
+			}
+			differ.Compare = kdiff.CompareDataKeys(kd.ignoreKeys)
+			return kd.diff(cmd.Context(), differ, printDifferences)
+		},
+	}
+
+	addCommonFlags(command, kd)
+	command.Flags().StringSliceVarP(&kd.ignoreKeys, "ignore-key", "i", []string{}, "List of key patterns to ignore when comparing data, e.g. *.crt (optional)")
+	return command
+}
+
+func validateIgnoreKeys(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Error: invalid --ignore-key pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
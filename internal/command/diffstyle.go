@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+	"sigs.k8s.io/yaml"
+)
+
+// Styles of the differences printed by the text output
+const (
+	// diffStyleFields lists the fields with differences
+	diffStyleFields = "fields"
+	// diffStyleUnified prints the git-style line diff of the values in YAML
+	diffStyleUnified = "unified"
+	// diffStyleSideBySide prints the values in YAML in two columns
+	diffStyleSideBySide = "side-by-side"
+)
+
+const (
+	// diffContextLines are the unchanged lines printed around each change
+	diffContextLines = 3
+	// defaultTerminalWidth is used when the width of the terminal is unknown, e.g. when stdout is a file
+	defaultTerminalWidth = 120
+	// minColumnWidth is the minimum width of each column of the side-by-side diff
+	minColumnWidth = 20
+	// pairDiffIndent indents the line diff of a pair of contexts like the differences of the fields
+	pairDiffIndent = "\t\t"
+	// pairDiffIndentWidth is the width of pairDiffIndent in a terminal, with a tab stop every 8 columns
+	pairDiffIndentWidth = 16
+)
+
+func validateDiffStyle(style string) error {
+	switch style {
+	case diffStyleFields, diffStyleUnified, diffStyleSideBySide:
+		return nil
+	}
+	return fmt.Errorf("Error: unsupported --diff-style %q, must be one of %s, %s or %s", style, diffStyleFields, diffStyleUnified, diffStyleSideBySide)
+}
+
+// formatPairDiff returns the line diff of the values of a resource in two contexts in the diff style, or an
+// empty string if their YAML has the same lines, e.g. values that must differ but are equal
+func formatPairDiff(style string, result kdiff.ResourceResult, sourceContext, targetContext string) (string, error) {
+	source, err := valuesYAML(result.Values[sourceContext])
+	if err != nil {
+		return "", err
+	}
+	target, err := valuesYAML(result.Values[targetContext])
+	if err != nil {
+		return "", err
+	}
+	hunks := diff.Hunks(diff.Lines(diff.SplitLines(source), diff.SplitLines(target)), diffContextLines)
+	if len(hunks) == 0 {
+		return "", nil
+	}
+	if style == diffStyleSideBySide {
+		return indent(formatSideBySide(hunks, sourceContext, targetContext, terminalWidth()-pairDiffIndentWidth), pairDiffIndent), nil
+	}
+	return indent(formatUnified(hunks, sourceContext, targetContext), pairDiffIndent), nil
+}
+
+// indent prefixes every line of s with prefix
+func indent(s, prefix string) string {
+	var sb strings.Builder
+	for _, line := range diff.SplitLines(s) {
+		sb.WriteString(prefix + line + "\n")
+	}
+	return sb.String()
+}
+
+// hasSuppressed checks if the rules suppressed differences of a resource between two contexts
+func hasSuppressed(suppressed []kdiff.ResourceResult, result kdiff.ResourceResult, sourceContext, targetContext string) bool {
+	for _, s := range suppressed {
+		if s.Kind != result.Kind || s.Namespace != result.Namespace || s.Name != result.Name || s.Path != result.Path {
+			continue
+		}
+		for _, d := range s.Differences {
+			if d.SourceContext == sourceContext && d.TargetContext == targetContext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// valuesYAML returns the values of a path in YAML. A single value, e.g. a whole object, is not wrapped in a list
+func valuesYAML(vals []any) (string, error) {
+	if len(vals) == 0 {
+		return "", nil
+	}
+	var v any = vals
+	if len(vals) == 1 {
+		v = vals[0]
+	}
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode values: %v", err)
+	}
+	return string(content), nil
+}
+
+// formatUnified returns the hunks in the unified format, with deleted lines in red and inserted lines in green
+func formatUnified(hunks [][]diff.Line, sourceContext, targetContext string) string {
+	var sb strings.Builder
+	sb.WriteString(color.RedString("--- %s", sourceContext) + "\n")
+	sb.WriteString(color.GreenString("+++ %s", targetContext) + "\n")
+	for _, hunk := range hunks {
+		sb.WriteString(color.CyanString(diff.HunkHeader(hunk)) + "\n")
+		for _, line := range hunk {
+			text := fmt.Sprintf("%c%s", line.Op, line.Text)
+			switch line.Op {
+			case diff.Delete:
+				text = color.RedString(text)
+			case diff.Insert:
+				text = color.GreenString(text)
+			}
+			sb.WriteString(text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// sideBySideRow is a row of the side-by-side diff, a line of the source and of the target. Line numbers are 0
+// when the row has no line of that side
+type sideBySideRow struct {
+	sourceLine, targetLine int
+	source, target         string
+	op                     byte
+}
+
+// formatSideBySide returns the hunks in two columns, the source on the left and the target on the right, that
+// fit in width. Long lines are wrapped. The gutter has | for changed lines, < for deleted lines and > for
+// inserted lines
+func formatSideBySide(hunks [][]diff.Line, sourceContext, targetContext string, width int) string {
+	// each side has a line number of 4 digits and a space, the gutter is a character between two spaces
+	column := (width - 2*5 - 3) / 2
+	if column < minColumnWidth {
+		column = minColumnWidth
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%4s %s   %4s %s\n", "", color.RedString(pad(sourceContext, column)), "", color.GreenString(targetContext)))
+	for _, hunk := range hunks {
+		sb.WriteString(color.CyanString(diff.HunkHeader(hunk)) + "\n")
+		for _, row := range sideBySideRows(hunk) {
+			left, right := wrap(row.source, column), wrap(row.target, column)
+			for j := 0; j < len(left) || j < len(right); j++ {
+				var l, r string
+				if j < len(left) {
+					l = left[j]
+				}
+				if j < len(right) {
+					r = right[j]
+				}
+				sourceLine, targetLine, op := lineNumber(row.sourceLine), lineNumber(row.targetLine), string(row.op)
+				if j > 0 {
+					sourceLine, targetLine = "", ""
+				}
+				l = pad(l, column)
+				switch row.op {
+				case '|':
+					l, r, op = color.RedString(l), color.GreenString(r), color.YellowString(op)
+				case '<':
+					l, op = color.RedString(l), color.RedString(op)
+				case '>':
+					r, op = color.GreenString(r), color.GreenString(op)
+				}
+				sb.WriteString(strings.TrimRight(fmt.Sprintf("%4s %s %s %4s %s", sourceLine, l, op, targetLine, r), " ") + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// sideBySideRows pairs the deleted and inserted lines of a hunk, so a changed line is in a single row
+func sideBySideRows(hunk []diff.Line) []sideBySideRow {
+	var rows []sideBySideRow
+	for i := 0; i < len(hunk); {
+		if hunk[i].Op == diff.Equal {
+			rows = append(rows, sideBySideRow{sourceLine: hunk[i].SourceLine, targetLine: hunk[i].TargetLine, source: hunk[i].Text, target: hunk[i].Text, op: ' '})
+			i++
+			continue
+		}
+		var deleted, inserted []diff.Line
+		for ; i < len(hunk) && hunk[i].Op == diff.Delete; i++ {
+			deleted = append(deleted, hunk[i])
+		}
+		for ; i < len(hunk) && hunk[i].Op == diff.Insert; i++ {
+			inserted = append(inserted, hunk[i])
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			row := sideBySideRow{op: '|'}
+			if j < len(deleted) {
+				row.sourceLine, row.source = deleted[j].SourceLine, deleted[j].Text
+			} else {
+				row.op = '>'
+			}
+			if j < len(inserted) {
+				row.targetLine, row.target = inserted[j].TargetLine, inserted[j].Text
+			} else {
+				row.op = '<'
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// wrap splits a line into lines of up to width characters
+func wrap(s string, width int) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(lines, string(runes))
+}
+
+// pad pads s with spaces up to width characters, colors are applied after padding so they do not count
+func pad(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func lineNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// terminalWidth returns the width of the terminal of stdout, $COLUMNS or defaultTerminalWidth
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPrintDifferencesDiffStyle(t *testing.T) {
+	result := kdiff.ResourceResult{
+		Kind: "Deployment", Namespace: "web", Name: "api", Path: "spec",
+		Values: map[string][]any{
+			"staging":    {map[string]any{"replicas": 1, "image": "api:1"}},
+			"production": {map[string]any{"replicas": 3, "image": "api:2"}},
+		},
+		Differences: []kdiff.Difference{
+			{SourceContext: "staging", TargetContext: "production", Change: kdiff.Change{Type: kdiff.Changed, Path: "image", Source: "api:1", Target: "api:2"}},
+		},
+	}
+	suppressed := result
+	suppressed.Differences = []kdiff.Difference{
+		{SourceContext: "staging", TargetContext: "production", Change: kdiff.Change{Type: kdiff.Changed, Path: "replicas", Source: 1, Target: 3}, Rule: "replicas"},
+	}
+	tests := []struct {
+		name       string
+		suppressed []kdiff.ResourceResult
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:    "line diff indented like the fields",
+			want:    []string{"\t\t-image: api:1\n", "\t\t+image: api:2\n", "\t\t-replicas: 1\n", "\t\t+replicas: 3\n"},
+			notWant: []string{"\t\timage: api:1 != api:2\n"},
+		},
+		{
+			name:       "fields of the pairs with suppressed differences",
+			suppressed: []kdiff.ResourceResult{suppressed},
+			want:       []string{"\t\timage: api:1 != api:2\n"},
+			notWant:    []string{"replicas"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kd := &kubediff{contexts: []string{"staging", "production"}, diffStyle: diffStyleUnified, suppressed: tt.suppressed}
+			out := captureStdout(t, func() { printDifferences(kd, []kdiff.ResourceResult{result}) })
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected %q in:\n%s", want, out)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("expected no %q in:\n%s", notWant, out)
+				}
+			}
+		})
+	}
+}
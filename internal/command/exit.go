@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+)
+
+// Exit codes of the commands
+const (
+	// ExitNoDrift is used when no differences are found
+	ExitNoDrift = 0
+	// ExitDrift is used when differences are found in the --fail-on categories
+	ExitDrift = 1
+	// ExitError is used when the command fails to run
+	ExitError = 2
+)
+
+// ErrDrift is returned by the commands when differences are found in the --fail-on categories
+var ErrDrift = errors.New("differences found")
+
+// Categories of differences used by --fail-on
+const (
+	// failOnMissing fails when a resource is not found in one of the contexts
+	failOnMissing = "missing"
+	// failOnValue fails when a value is different between contexts
+	failOnValue = "value"
+	// failOnCount fails when an element or field only exists in one of the contexts
+	failOnCount = "count"
+	// failOnNone never fails, differences are only reported
+	failOnNone = "none"
+)
+
+var defaultFailOn = []string{failOnMissing, failOnValue, failOnCount}
+
+// validateFailOn validates the --fail-on categories. none can not be combined with other categories
+func validateFailOn(categories []string) error {
+	for _, category := range categories {
+		switch category {
+		case failOnMissing, failOnValue, failOnCount, failOnNone:
+		default:
+			return fmt.Errorf("Error: unsupported --fail-on category %q, must be one of %s", category, strings.Join([]string{failOnMissing, failOnValue, failOnCount, failOnNone}, ", "))
+		}
+	}
+	if len(categories) > 1 && stringInSlice(failOnNone, categories) {
+		return fmt.Errorf("Error: --fail-on %s can not be combined with other categories", failOnNone)
+	}
+	return nil
+}
+
+// checkDrift returns ErrDrift if any resource of the report has differences in the --fail-on categories
+func (kd *kubediff) checkDrift(report *kdiff.Report) error {
+	for _, result := range report.Resources {
+		if failsOn(result, kd.failOn) {
+			return ErrDrift
+		}
+	}
+	return nil
+}
+
+// failsOn reports if the resource has differences in any of the categories
+func failsOn(r kdiff.ResourceResult, categories []string) bool {
+	for _, category := range categories {
+		switch category {
+		case failOnMissing:
+			if len(r.Missing) > 0 {
+				return true
+			}
+		case failOnValue, failOnCount:
+			for _, d := range r.Differences {
+				if differenceCategory(d.Change) == category {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// differenceCategory returns the --fail-on category of a difference. Values that are expected to differ
+// but are equal are value differences
+func differenceCategory(d kdiff.Change) string {
+	if d.Type == kdiff.Changed || d.Type == kdiff.Unchanged {
+		return failOnValue
+	}
+	return failOnCount
+}
@@ -0,0 +1,25 @@
+package commands
+
+import "testing"
+
+func TestValidateFailOn(t *testing.T) {
+	tests := []struct {
+		categories []string
+		wantErr    bool
+	}{
+		{categories: defaultFailOn},
+		{categories: []string{"value"}},
+		{categories: []string{"none"}},
+		{categories: []string{}},
+		{categories: []string{"values"}, wantErr: true},
+		{categories: []string{"none", "value"}, wantErr: true},
+		{categories: []string{"missing", "none"}, wantErr: true},
+		{categories: []string{"none", "none"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateFailOn(tt.categories)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateFailOn(%v) returned error %v, want error %t", tt.categories, err, tt.wantErr)
+		}
+	}
+}
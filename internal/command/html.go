@@ -0,0 +1,415 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	log "github.com/sirupsen/logrus"
+)
+
+// Drift status of a resource in a context, shown in the summary matrix of the HTML report
+const (
+	statusOK       = "ok"
+	statusDrift    = "drift"
+	statusExpected = "expected"
+	statusMissing  = "missing"
+)
+
+// htmlReport is the data of the HTML report
+type htmlReport struct {
+	GeneratedAt string
+	Command     string
+	Contexts    []htmlContext
+	Namespaces  []string
+	Kinds       []string
+	Resources   []*htmlResource
+	Errors      []kdiff.ResourceError
+	// Drifted is the number of resources missing in a context or with differences that are not expected
+	Drifted int
+}
+
+// htmlContext describes the source of a context
+type htmlContext struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+// htmlResource is a compared resource with the results of every path with differences or expected differences
+type htmlResource struct {
+	ID        string
+	Kind      string
+	Namespace string
+	Name      string
+	Names     string
+	Status    []string
+	Drift     bool
+	Results   []htmlResult
+}
+
+type htmlResult struct {
+	Path        string
+	Missing     []string
+	Differences []htmlDifference
+	LineDiffs   []htmlLineDiff
+}
+
+type htmlDifference struct {
+	SourceContext string
+	TargetContext string
+	Path          string
+	Source        string
+	Target        string
+	Rule          string
+	Expected      bool
+}
+
+// htmlLineDiff is the line diff of the values of a resource in two contexts in YAML
+type htmlLineDiff struct {
+	SourceContext string
+	TargetContext string
+	Lines         []htmlLine
+}
+
+type htmlLine struct {
+	Class string
+	Text  string
+}
+
+// serverVersionTimeout is the time to get the server version of each context, so an unreachable cluster does
+// not block the report
+const serverVersionTimeout = 5 * time.Second
+
+// printHTML writes a self-contained HTML report with a summary matrix of the compared resources and contexts,
+// the differences of each resource and the metadata of the run: the sources and server versions of the contexts
+// and the time of the report
+func (kd *kubediff) printHTML(ctx context.Context, w io.Writer, report *kdiff.Report, sources map[string]kdiff.Source) error {
+	data := &htmlReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Command:     strings.Join(os.Args, " "),
+		Contexts:    kd.htmlContexts(ctx, report.Contexts, sources),
+		Errors:      report.Errors,
+	}
+
+	resources := make(map[kdiff.Meta]*htmlResource)
+	resource := func(kind, namespace, name string) *htmlResource {
+		meta := kdiff.Meta{Kind: kind, Namespace: namespace, Name: name}
+		r, ok := resources[meta]
+		if !ok {
+			r = &htmlResource{Kind: kind, Namespace: namespace, Name: name}
+			resources[meta] = r
+			data.Resources = append(data.Resources, r)
+		}
+		return r
+	}
+	add := func(result kdiff.ResourceResult, expected bool) {
+		r := resource(result.Kind, result.Namespace, result.Name)
+		if names := formatNames(report.Contexts, result.Names); names != "" {
+			r.Names = names
+		}
+		r.Results = append(r.Results, newHTMLResult(result, expected))
+	}
+	for _, result := range report.Resources {
+		add(result, false)
+	}
+	for _, result := range report.Suppressed {
+		add(result, true)
+	}
+	// resources without differences are in the matrix too
+	for _, meta := range report.Compared {
+		resource(meta.Kind, meta.Namespace, meta.Name)
+	}
+	sort.SliceStable(data.Resources, func(i, j int) bool {
+		a, b := data.Resources[i], data.Resources[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	namespaces, kinds := make(map[string]bool), make(map[string]bool)
+	for i, r := range data.Resources {
+		r.ID = fmt.Sprintf("resource-%d", i)
+		r.Status = resourceStatus(r, report.Contexts)
+		for _, status := range r.Status {
+			if status == statusDrift || status == statusMissing {
+				r.Drift = true
+			}
+		}
+		if r.Drift {
+			data.Drifted++
+		}
+		namespaces[r.Namespace], kinds[r.Kind] = true, true
+	}
+	data.Namespaces, data.Kinds = sortedKeys(namespaces), sortedKeys(kinds)
+	return htmlTemplate.Execute(w, data)
+}
+
+// htmlContexts describes the sources of the contexts. The server versions of clusters are requested
+// concurrently, each with serverVersionTimeout. A failure is only logged as the report is still useful without it
+func (kd *kubediff) htmlContexts(ctx context.Context, names []string, sources map[string]kdiff.Source) []htmlContext {
+	contexts := make([]htmlContext, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		contexts[i].Name = name
+		for _, spec := range kd.sourceSpecs {
+			if spec.Name == name {
+				contexts[i].Source = spec.Type
+				if spec.Location != "" {
+					contexts[i].Source += ":" + spec.Location
+				}
+			}
+		}
+		src := sources[name]
+		if src == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(c *htmlContext) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, serverVersionTimeout)
+			defer cancel()
+			version, err := kdiff.ServerVersion(ctx, src)
+			if err != nil {
+				log.WithField("context", c.Name).Warnf("Failed to get the server version: %v", err)
+				version = "unknown"
+			}
+			c.Version = version
+		}(&contexts[i])
+	}
+	wg.Wait()
+	return contexts
+}
+
+func newHTMLResult(result kdiff.ResourceResult, expected bool) htmlResult {
+	r := htmlResult{Path: result.Path, Missing: result.Missing}
+	for i, d := range result.Differences {
+		r.Differences = append(r.Differences, htmlDifference{
+			SourceContext: d.SourceContext,
+			TargetContext: d.TargetContext,
+			Path:          dash(d.Path),
+			Source:        htmlValue(d.Change, true),
+			Target:        htmlValue(d.Change, false),
+			Rule:          d.Rule,
+			Expected:      expected,
+		})
+		if expected || (i > 0 && result.Differences[i-1].SourceContext == d.SourceContext && result.Differences[i-1].TargetContext == d.TargetContext) {
+			continue
+		}
+		if lineDiff, ok := newHTMLLineDiff(result, d.SourceContext, d.TargetContext); ok {
+			r.LineDiffs = append(r.LineDiffs, lineDiff)
+		}
+	}
+	return r
+}
+
+// htmlValue returns the value of a change in the source or in the target context
+func htmlValue(d kdiff.Change, source bool) string {
+	switch {
+	case d.Type == kdiff.Unchanged:
+		return "(equal)"
+	case d.Type == kdiff.Added && source, d.Type == kdiff.Removed && !source:
+		return "(missing)"
+	case source:
+		return oneLine(d.Source)
+	}
+	return oneLine(d.Target)
+}
+
+// newHTMLLineDiff returns the line diff of the values of a resource in two contexts in YAML, false if their
+// YAML has the same lines
+func newHTMLLineDiff(result kdiff.ResourceResult, sourceContext, targetContext string) (htmlLineDiff, bool) {
+	lineDiff := htmlLineDiff{SourceContext: sourceContext, TargetContext: targetContext}
+	source, err := valuesYAML(result.Values[sourceContext])
+	if err != nil {
+		return lineDiff, false
+	}
+	target, err := valuesYAML(result.Values[targetContext])
+	if err != nil {
+		return lineDiff, false
+	}
+	hunks := diff.Hunks(diff.Lines(diff.SplitLines(source), diff.SplitLines(target)), diffContextLines)
+	for _, hunk := range hunks {
+		lineDiff.Lines = append(lineDiff.Lines, htmlLine{Class: "hunk", Text: diff.HunkHeader(hunk)})
+		for _, line := range hunk {
+			class := "equal"
+			switch line.Op {
+			case diff.Delete:
+				class = "delete"
+			case diff.Insert:
+				class = "insert"
+			}
+			lineDiff.Lines = append(lineDiff.Lines, htmlLine{Class: class, Text: string(line.Op) + line.Text})
+		}
+	}
+	return lineDiff, len(hunks) > 0
+}
+
+// resourceStatus returns the drift status of a resource in each context: missing, drift when the context is
+// part of a difference, expected when it is only part of expected differences, or ok
+func resourceStatus(r *htmlResource, contexts []string) []string {
+	status := make([]string, 0, len(contexts))
+	for _, context := range contexts {
+		s := statusOK
+		for _, result := range r.Results {
+			if stringInSlice(context, result.Missing) {
+				s = statusMissing
+			}
+			for _, d := range result.Differences {
+				if d.SourceContext != context && d.TargetContext != context {
+					continue
+				}
+				switch {
+				case !d.Expected && s != statusMissing:
+					s = statusDrift
+				case d.Expected && s == statusOK:
+					s = statusExpected
+				}
+			}
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>kubediff report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { margin-bottom: 0.2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.meta td:first-child { font-weight: bold; }
+.status { text-align: center; font-weight: bold; }
+.ok { background: #dafbe1; color: #116329; }
+.drift { background: #ffebe9; color: #a40e26; }
+.missing { background: #fff8c5; color: #7d4e00; }
+.expected { background: #ddf4ff; color: #0550ae; }
+.filters { margin: 1em 0; }
+.filters label { margin-right: 1em; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: 0.5em 0; padding: 0.5em 1em; }
+summary { cursor: pointer; font-weight: bold; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+pre span { display: block; }
+.delete { background: #ffebe9; }
+.insert { background: #dafbe1; }
+.hunk { color: #0550ae; }
+.errors td { color: #a40e26; }
+</style>
+</head>
+<body>
+<h1>kubediff report</h1>
+<table class="meta">
+<tr><td>Generated at</td><td>{{.GeneratedAt}}</td></tr>
+<tr><td>Command</td><td><code>{{.Command}}</code></td></tr>
+<tr><td>Resources with drift</td><td>{{.Drifted}} of {{len .Resources}} compared</td></tr>
+</table>
+<table>
+<tr><th>Context</th><th>Source</th><th>Server version</th></tr>
+{{- range .Contexts}}
+<tr><td>{{.Name}}</td><td>{{.Source}}</td><td>{{if .Version}}{{.Version}}{{else}}-{{end}}</td></tr>
+{{- end}}
+</table>
+
+<div class="filters">
+<label>Namespace <select id="namespace"><option value="">all</option>{{range .Namespaces}}<option value="{{.}}">{{if .}}{{.}}{{else}}(cluster){{end}}</option>{{end}}</select></label>
+<label>Kind <select id="kind"><option value="">all</option>{{range .Kinds}}<option>{{.}}</option>{{end}}</select></label>
+<label><input type="checkbox" id="drift"> Only resources with drift</label>
+</div>
+
+<h2>Summary</h2>
+{{- if not .Resources}}
+<p>No resources found</p>
+{{- else}}
+<table>
+<tr><th>Kind</th><th>Namespace</th><th>Name</th>{{range .Contexts}}<th>{{.Name}}</th>{{end}}</tr>
+{{- range .Resources}}
+<tr class="resource" data-namespace="{{.Namespace}}" data-kind="{{.Kind}}" data-drift="{{.Drift}}">
+<td>{{.Kind}}</td><td>{{.Namespace}}</td><td>{{if .Results}}<a href="#{{.ID}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}{{.Names}}</td>
+{{- range .Status}}<td class="status {{.}}">{{.}}</td>{{end}}
+</tr>
+{{- end}}
+</table>
+
+<h2>Differences</h2>
+{{- range .Resources}}
+{{- if .Results}}
+<details id="{{.ID}}" class="resource" data-namespace="{{.Namespace}}" data-kind="{{.Kind}}" data-drift="{{.Drift}}">
+<summary>{{.Kind}} {{if .Namespace}}{{.Namespace}}/{{end}}{{.Name}}{{.Names}}</summary>
+{{- range .Results}}
+<h4>{{.Path}}</h4>
+{{- if .Missing}}<p class="missing">Not found in {{range $i, $c := .Missing}}{{if $i}}, {{end}}{{$c}}{{end}}</p>{{end}}
+{{- if .Differences}}
+<table>
+<tr><th>Field</th><th>Contexts</th><th>Source</th><th>Target</th><th>Rule</th></tr>
+{{- range .Differences}}
+<tr{{if .Expected}} class="expected"{{end}}><td><code>{{.Path}}</code></td><td>{{.SourceContext}} &rarr; {{.TargetContext}}</td><td><code>{{.Source}}</code></td><td><code>{{.Target}}</code></td><td>{{.Rule}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .LineDiffs}}
+<details>
+<summary>YAML diff between {{.SourceContext}} and {{.TargetContext}}</summary>
+<pre>{{range .Lines}}<span class="{{.Class}}">{{.Text}}</span>{{end}}</pre>
+</details>
+{{- end}}
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{- if .Errors}}
+<h2>Errors</h2>
+<table class="errors">
+<tr><th>Context</th><th>Namespace</th><th>Resource</th><th>Name</th><th>Path</th><th>Message</th></tr>
+{{- range .Errors}}
+<tr><td>{{.Context}}</td><td>{{.Namespace}}</td><td>{{.Resource}}</td><td>{{.Name}}</td><td>{{.Path}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<script>
+(function () {
+  var namespace = document.getElementById("namespace");
+  var kind = document.getElementById("kind");
+  var drift = document.getElementById("drift");
+  function filter() {
+    document.querySelectorAll(".resource").forEach(function (el) {
+      var visible = (!namespace.value || el.dataset.namespace === namespace.value) &&
+        (!kind.value || el.dataset.kind === kind.value) &&
+        (!drift.checked || el.dataset.drift === "true");
+      el.style.display = visible ? "" : "none";
+    });
+  }
+  [namespace, kind, drift].forEach(function (el) { el.addEventListener("change", filter); });
+})();
+</script>
+</body>
+</html>
+`))
@@ -3,18 +3,22 @@ package commands
 import (
 	"fmt"
 	"os"
+	"path"
 	"strings"
 	"text/tabwriter"
 
-	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
+	"github.com/eduardodbr/kubediff/internal/image"
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
 	"github.com/fatih/color"
-	"github.com/go-test/deep"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 func NewImages() *cobra.Command {
-	var kubeconfig string
+	var registryAliases []string
+	var live bool
+	opts := kdiff.ImageOptions{}
 	kd := &kubediff{
 		resources: []string{"deployment", "statefulset", "daemonset"},
 	}
@@ -22,37 +26,57 @@ func NewImages() *cobra.Command {
 		Use:   "images",
 		Short: "Detect different image tags between Kubernetes clusters",
 		Long:  "A CLI tool to detect differences between image tags in deployments with the same name in Kubernetes clusters",
-		Run: func(cmd *cobra.Command, args []string) {
-			if len(kd.contexts) < 2 {
-				log.Fatal("Error: at least two contexts are required")
-				return
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
 			}
-			clients, err := k8s.CreateClients(kubeconfig, kd.contexts)
+			if err := validateImageCompare(opts.Compare); err != nil {
+				return err
+			}
+			aliases, err := parseRegistryAliases(registryAliases)
 			if err != nil {
-				log.Fatalf("Error: failed to create kubernetes clients: %v", err)
-				return
+				return err
+			}
+			if live && (opts.Compare != kdiff.ImageCompareReference || opts.IgnoreTag || len(aliases) > 0 || kd.ignoreContainerRegistry) {
+				return fmt.Errorf("Error: --live compares digests and can not be used with --compare, --ignore-tag, --registry-alias or --ignore-container-registry")
 			}
-			paths := []string{"Spec.Template.Spec.Containers[*].Image", "Spec.Template.Spec.InitContainers[*].Image"}
-			for _, path := range paths {
-				kd.path = path
-				if err := kd.findDifferences(cmd.Context(), clients, printImagesDifferences); err != nil {
-					log.Fatal(err)
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			if live {
+				differ, err := kd.newDiffer("spec.selector")
+				if err != nil {
+					return err
 				}
+				differ.Resolve = kdiff.ResolveRunningImages()
+				differ.Compare = kdiff.CompareRunningImages
+				return kd.diff(cmd.Context(), differ, printRunningImagesDifferences)
+			}
+			differ, err := kd.newDiffer("spec.template.spec.containers[*]", "spec.template.spec.initContainers[*]")
+			if err != nil {
+				return err
 			}
+			differ.Resolve = kdiff.ResolveImages
+			opts.IgnoreRegistry = kd.ignoreContainerRegistry
+			opts.RegistryAliases = aliases
+			differ.Compare = kdiff.CompareImageReferences(opts)
+			return kd.diff(cmd.Context(), differ, printImagesDifferences)
 		},
 	}
 
-	command.Flags().StringSliceVarP(&kd.contexts, "contexts", "c", []string{}, "List of contexts (mandatory)")
-	command.Flags().StringSliceVarP(&kd.namespaces, "namespaces", "n", []string{""}, "List of namespaces (optional)")
-	command.Flags().StringSliceVarP(&kd.labels, "labels", "l", []string{}, "List of labels (optional)")
+	addCommonFlags(command, kd)
 	command.Flags().BoolVar(&kd.ignoreContainerRegistry, "ignore-container-registry", false, "Ignore container registry in image tags (optional)")
-	command.Flags().StringVar(&kubeconfig, "kubeconfig", defaultKubeconfig(), "Path to the kubeconfig file  (optional, uses $HOME/.kube/config by default)")
-	command.Flags().BoolVar(&kd.ignoreNonExistent, "ignore-non-existent", false, "Ignore comparison when resource do not exist in one of the contexts (optional)")
-	command.MarkFlagRequired("contexts")
+	command.Flags().BoolVar(&opts.IgnoreTag, "ignore-tag", false, "Ignore the tag of the images, e.g. to only compare digests (optional)")
+	command.Flags().StringVar(&opts.Compare, "compare", kdiff.ImageCompareReference, "Part of the images to compare, one of digest, tag or repository, compares the whole reference by default (optional)")
+	command.Flags().BoolVar(&live, "live", false, "Compare the image digests run by the pods of each workload instead of the images of the pod template (optional)")
+	command.Flags().StringSliceVar(&registryAliases, "registry-alias", []string{}, "List of registry aliases in the format <alias>=<registry>, e.g. mirror.corp/=docker.io/ (optional)")
 	return command
 }
 
-func printImagesDifferences(kd *kubediff, namespace string, m map[string]map[string][]any) {
+func printImagesDifferences(kd *kubediff, results []kdiff.ResourceResult) {
+	if len(results) == 0 {
+		log.Infoln(color.GreenString("No differences found"))
+		return
+	}
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
 	var header strings.Builder
 	header.WriteString("Service\tNamespace\t")
@@ -60,66 +84,242 @@ func printImagesDifferences(kd *kubediff, namespace string, m map[string]map[str
 		header.WriteString(context)
 		header.WriteString("\t")
 	}
-	found := false
+	header.WriteString("Version\t")
 	var sb strings.Builder
-	for resourceName, contextsMap := range m {
-		if (!allElementsExist(kd.contexts, contextsMap) && !kd.ignoreNonExistent) || !kd.allValuesAreEqual(contextsMap) {
-			sb.WriteString(fmt.Sprintf("%s\t%s\t", resourceName, namespace))
-			for _, context := range kd.contexts {
-				sb.WriteString(fmt.Sprintf("%s\t", contextsMap[context]))
+	for _, result := range results {
+		sb.WriteString(fmt.Sprintf("%s\t%s\t", result.Name, result.Namespace))
+		for _, context := range kd.contexts {
+			images, names := containerImages(result.Values[context])
+			list := make([]string, 0, len(names))
+			for _, name := range names {
+				list = append(list, images[name])
 			}
-			sb.WriteString("\n")
-			found = true
+			sb.WriteString(fmt.Sprintf("%s\t", list))
 		}
+		sb.WriteString(fmt.Sprintf("%s\t", versionSkew(kd.contexts, result)))
+		sb.WriteString("\n")
 	}
-	if !found {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, header.String())
+	fmt.Fprintln(w, sb.String())
+	w.Flush()
+}
+
+// printRunningImagesDifferences prints a table with a row for each container with different digests and a column
+// with the digests run in each context. Contexts whose pods run more than one digest are listed as mixed
+func printRunningImagesDifferences(kd *kubediff, results []kdiff.ResourceResult) {
+	if len(results) == 0 {
 		log.Infoln(color.GreenString("No differences found"))
 		return
 	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	var header strings.Builder
+	header.WriteString("Service\tNamespace\tContainer\t")
+	for _, context := range kd.contexts {
+		header.WriteString(context)
+		header.WriteString("\t")
+	}
+	header.WriteString("Mixed\t")
+	var sb strings.Builder
+	for _, result := range results {
+		containers := make(map[string]map[string][]string, len(kd.contexts))
+		for _, context := range kd.contexts {
+			containers[context] = runningImages(result.Values[context])
+		}
+		var names []string
+		for _, d := range result.Differences {
+			name := strings.TrimSuffix(strings.TrimPrefix(d.Path, "containers[name="), "].imageIDs")
+			if !stringInSlice(name, names) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			// the resource was not found in some contexts
+			names = append(names, "")
+		}
+		for _, name := range names {
+			sb.WriteString(fmt.Sprintf("%s\t%s\t%s\t", result.Name, result.Namespace, dash(name)))
+			var mixed []string
+			for _, context := range kd.contexts {
+				ids, ok := containers[context][name]
+				switch {
+				case stringInSlice(context, result.Missing):
+					sb.WriteString("not found")
+				case !ok:
+					sb.WriteString("-")
+				default:
+					sb.WriteString(strings.Join(ids, ","))
+				}
+				if len(ids) > 1 {
+					mixed = append(mixed, context)
+				}
+				sb.WriteString("\t")
+			}
+			sb.WriteString(fmt.Sprintf("%s\t\n", dash(strings.Join(mixed, ","))))
+		}
+	}
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, header.String())
 	fmt.Fprintln(w, sb.String())
 	w.Flush()
-	return
 }
 
-func allElementsExist(elements []string, m map[string][]any) bool {
-	for _, element := range elements {
-		if _, ok := m[element]; !ok {
-			return false
+// runningImages returns the short digests of each container resolved by kdiff.ResolveRunningImages
+func runningImages(vals []any) map[string][]string {
+	containers := make(map[string][]string)
+	for _, val := range vals {
+		container, ok := val.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(container["name"])
+		ids, _ := container["imageIDs"].([]any)
+		for _, id := range ids {
+			containers[name] = append(containers[name], shortDigest(fmt.Sprint(id)))
 		}
 	}
-	return true
+	return containers
 }
 
-func (kd *kubediff) allValuesAreEqual(m map[string][]any) bool {
-	if len(m) == 0 {
-		return true
-	}
-	var target []any
-	for _, source := range m {
-		if kd.ignoreContainerRegistry {
-			for i, value := range source {
-				str, ok := value.(string)
-				if !ok {
-					return false
-				}
-				source[i] = removeContainerRegistry(str)
+// shortDigest returns the first 12 characters of the hex of a digest, e.g. sha256:0123456789ab
+func shortDigest(digest string) string {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || len(hex) <= 12 {
+		return digest
+	}
+	return algorithm + ":" + hex[:12]
+}
+
+// containerImages returns the images resolved by kdiff.ResolveImages by container name and the names in order
+func containerImages(vals []any) (map[string]string, []string) {
+	images := make(map[string]string, len(vals))
+	names := make([]string, 0, len(vals))
+	for _, val := range vals {
+		container, ok := val.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(container["name"])
+		images[name] = fmt.Sprint(container["image"])
+		names = append(names, name)
+	}
+	return images, names
+}
+
+// versionSkew describes which contexts are ahead of the others for each container whose image tag is a version
+// in every context, e.g. production ahead of staging. Containers are matched by name. Returns - if the versions
+// can not be compared
+func versionSkew(contexts []string, result kdiff.ResourceResult) string {
+	images := make(map[string]map[string]string, len(contexts))
+	var names []string
+	several := false
+	for _, context := range contexts {
+		if stringInSlice(context, result.Missing) {
+			continue
+		}
+		contextImages, contextNames := containerImages(result.Values[context])
+		images[context] = contextImages
+		several = several || len(contextNames) > 1
+		for _, name := range contextNames {
+			if !stringInSlice(name, names) {
+				names = append(names, name)
 			}
 		}
-		if target == nil {
-			target = source
-		} else if diffs := deep.Equal(source, target); len(diffs) > 0 {
-			return false
+	}
+
+	var skews []string
+	for _, name := range names {
+		versions := make(map[string]*version.Version)
+		var imageName string
+		versioned := true
+		for _, context := range contexts {
+			contextImages, ok := images[context]
+			if !ok {
+				continue
+			}
+			img, ok := contextImages[name]
+			if !ok {
+				versioned = false
+				break
+			}
+			ref, err := image.Parse(img)
+			if err != nil {
+				versioned = false
+				break
+			}
+			v, ok := ref.Version()
+			// images of mirrors are compared, only the name of the image must match
+			repository := path.Base(ref.Repository)
+			if !ok || imageName != "" && repository != imageName {
+				versioned = false
+				break
+			}
+			imageName = repository
+			versions[context] = v
 		}
+		if !versioned || len(versions) < 2 {
+			continue
+		}
+		skew := describeSkew(contexts, versions)
+		if skew == "" {
+			continue
+		}
+		if several {
+			// identify the container when there are several
+			skew = name + ": " + skew
+		}
+		skews = append(skews, skew)
+	}
+	if len(skews) == 0 {
+		return "-"
 	}
-	return true
+	return strings.Join(skews, "; ")
 }
 
-func removeContainerRegistry(image string) string {
-	parts := strings.Split(image, "/")
-	if len(parts) < 2 {
-		return image
+// describeSkew returns the contexts with the newest version ahead of the remaining ones, or an empty
+// string if every context has the same version
+func describeSkew(contexts []string, versions map[string]*version.Version) string {
+	var newest *version.Version
+	for _, v := range versions {
+		if newest == nil || v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+	var ahead, behind []string
+	for _, context := range contexts {
+		v, ok := versions[context]
+		switch {
+		case !ok:
+		case v.LessThan(newest):
+			behind = append(behind, context)
+		default:
+			ahead = append(ahead, context)
+		}
+	}
+	if len(behind) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s ahead of %s", strings.Join(ahead, ","), strings.Join(behind, ","))
+}
+
+// validateImageCompare validates the part of the images to compare
+func validateImageCompare(compare string) error {
+	switch compare {
+	case kdiff.ImageCompareReference, kdiff.ImageCompareDigest, kdiff.ImageCompareTag, kdiff.ImageCompareRepository:
+		return nil
+	}
+	return fmt.Errorf("Error: invalid --compare %q, must be one of %s, %s or %s", compare, kdiff.ImageCompareDigest, kdiff.ImageCompareTag, kdiff.ImageCompareRepository)
+}
+
+// parseRegistryAliases parses the registry aliases in the format <alias>=<registry>
+func parseRegistryAliases(aliases []string) (map[string]string, error) {
+	result := make(map[string]string, len(aliases))
+	for _, s := range aliases {
+		alias, registry, err := image.ParseAlias(s)
+		if err != nil {
+			return nil, fmt.Errorf("Error: %v", err)
+		}
+		result[alias] = registry
 	}
-	return strings.Join(parts[1:], "/")
+	return result, nil
 }
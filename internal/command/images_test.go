@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"testing"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+)
+
+func TestVersionSkew(t *testing.T) {
+	container := func(name, image string) any {
+		return map[string]any{"name": name, "image": image}
+	}
+	tests := []struct {
+		name   string
+		values map[string][]any
+		want   string
+	}{
+		{
+			name: "single container",
+			values: map[string][]any{
+				"staging":    {container("app", "nginx:1.26")},
+				"production": {container("app", "nginx:1.25")},
+			},
+			want: "staging ahead of production",
+		},
+		{
+			name: "reordered and added containers",
+			values: map[string][]any{
+				"staging":    {container("proxy", "envoy:1.30"), container("app", "nginx:1.26")},
+				"production": {container("app", "nginx:1.25"), container("tools", "busybox:1.36")},
+			},
+			want: "app: staging ahead of production",
+		},
+		{
+			name: "different images of a container",
+			values: map[string][]any{
+				"staging":    {container("app", "envoy:1.30")},
+				"production": {container("app", "nginx:1.25")},
+			},
+			want: "-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := versionSkew([]string{"staging", "production"}, kdiff.ResourceResult{Values: tt.values})
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
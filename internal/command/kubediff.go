@@ -1,25 +1,29 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
-	"reflect"
+	"regexp"
 	"strings"
-	"sync"
 
-	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
-	"github.com/eduardodbr/kubediff/internal/resource"
+	"github.com/eduardodbr/kubediff/internal/diff"
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+	"github.com/eduardodbr/kubediff/internal/source"
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
 	"github.com/fatih/color"
-	"github.com/go-test/deep"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"golang.org/x/sync/errgroup"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/util/homedir"
 )
 
+// hmacKeyEnv is the environment variable with the key used to fingerprint secret values
+const hmacKeyEnv = "KUBEDIFF_HMAC_KEY"
+
 type kubediff struct {
+	kubeconfig              string
 	contexts                []string
 	namespaces              []string
 	labels                  []string
@@ -27,243 +31,398 @@ type kubediff struct {
 	path                    string
 	ignoreContainerRegistry bool
 	ignoreNonExistent       bool
+	ignoreDefaults          bool
+	diffStyle               string
 	ignoreEnv               []string
+	ignoreKeys              []string
+	output                  string
+	failOn                  []string
+	sources                 []string
+	baseline                string
+	consensus               bool
+	continueOnError         bool
+	hmacKeyFile             string
+	rulesFile               string
+	namespaceMap            []string
+	nameRewrite             []string
+	// hmacKey is the key read from --hmac-key-file or $KUBEDIFF_HMAC_KEY
+	hmacKey []byte
+	// rules are the rules read from --rules
+	rules []kdiff.Rule
+	// namespaceMappings and nameRewrites are parsed from --namespace-map and --name-rewrite
+	namespaceMappings map[string]map[string]string
+	nameRewrites      map[string][]kdiff.NameRewrite
+	// sourceSpecs describe the source of each context, including --source
+	sourceSpecs []source.Spec
+	// suppressed are the results with differences suppressed by the rules, set before printing the text output
+	suppressed []kdiff.ResourceResult
 }
 
 func Newkubediff() *cobra.Command {
-	var kubeconfig string
 	kd := &kubediff{}
 	command := &cobra.Command{
 		Use:   "kubediff",
 		Short: "A CLI tool to detect differences between Kubernetes resources",
 		Long: `kubediff is a command-line tool that helps with detecting differences between 
-		Kubernetes resources in different clusters using a dot path to identity the objects to be compared.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			if len(kd.contexts) < 2 {
-				log.Fatal("Error: at least two contexts are required")
-				return
+		Kubernetes resources in different clusters using a JSONPath to identity the objects to be compared.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := kd.path
+			if path == "" {
+				path = fieldpath.Root
+			}
+			wholeObjects := fieldpath.IsRoot(path)
+			if kd.ignoreDefaults && !wholeObjects {
+				return fmt.Errorf("Error: --ignore-defaults can only be used to compare whole objects, without --path")
+			}
+			if err := kd.validate(); err != nil {
+				return err
 			}
-			clients, err := k8s.CreateClients(kubeconfig, kd.contexts)
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer(path)
 			if err != nil {
-				log.Fatalf("Error: failed to create kubernetes clients: %v", err)
-				return
+				return err
 			}
-			if err := kd.findDifferences(cmd.Context(), clients, printDifferences); err != nil {
-				log.Fatal(err)
+			if wholeObjects {
+				differ.Resolve = kdiff.NormalizeObjects(kd.ignoreDefaults)
+				differ.Compare = kdiff.CompareObjects
 			}
+			return kd.diff(cmd.Context(), differ, printDifferences)
 		},
 	}
 
-	command.Flags().StringSliceVarP(&kd.contexts, "contexts", "c", []string{}, "List of contexts (mandatory)")
-	command.Flags().StringVarP(&kd.path, "path", "p", "", "Dot path to the field to compare (mandatory)")
+	addCommonFlags(command, kd)
+	command.Flags().StringVarP(&kd.path, "path", "p", "", "JSONPath to the field to compare, e.g. spec.template.spec.containers[*].image, compares the whole objects if not set (optional)")
 	command.Flags().StringSliceVarP(&kd.resources, "resources", "r", []string{""}, "List of resources to detect changes (mandatory)")
-	command.Flags().StringSliceVarP(&kd.namespaces, "namespaces", "n", []string{""}, "List of namespaces (optional)")
-	command.Flags().StringSliceVarP(&kd.labels, "labels", "l", []string{}, "List of labels to filter resources (optional)")
-	command.Flags().StringVar(&kubeconfig, "kubeconfig", defaultKubeconfig(), "Path to the kubeconfig file (optional, uses $HOME/.kube/config by default)")
-	command.Flags().BoolVar(&kd.ignoreNonExistent, "ignore-non-existent", false, "Ignore comparison when resource do not exist in one of the contexts (optional)")
-	command.MarkFlagRequired("contexts")
-	command.MarkFlagRequired("path")
+	command.Flags().StringVar(&kd.diffStyle, "diff-style", diffStyleFields, "Style of the differences of the text output, one of fields, unified or side-by-side. unified and side-by-side print the line diff of the values in YAML (optional)")
+	command.Flags().BoolVar(&kd.ignoreDefaults, "ignore-defaults", false, "Ignore the fields set to the default of the API server, e.g. dnsPolicy: ClusterFirst, when comparing whole objects (optional)")
+	command.Flags().StringVar(&kd.hmacKeyFile, "hmac-key-file", "", "File with the key used to fingerprint secret values with HMAC-SHA256, defaults to $"+hmacKeyEnv+" (optional)")
 	command.MarkFlagRequired("resources")
+	addProfileFlags(command)
 	return command
 }
 
-func defaultKubeconfig() string {
-	if home := homedir.HomeDir(); home != "" {
-		return filepath.Join(home, ".kube", "config")
-	}
-	log.Fatal("Error: kubeconfig not set")
-	return ""
+// addCommonFlags adds the flags shared by every command that compares contexts
+func addCommonFlags(command *cobra.Command, kd *kubediff) {
+	command.Flags().StringSliceVarP(&kd.contexts, "contexts", "c", []string{}, "List of contexts or snapshot archives (mandatory unless --source is used)")
+	command.Flags().StringSliceVarP(&kd.sources, "source", "s", []string{}, "List of sources in the format <name>=<type>:<location>, type is one of ctx, file, dir, stdin or snapshot (optional)")
+	command.Flags().StringSliceVarP(&kd.namespaces, "namespaces", "n", []string{""}, "List of namespaces (optional)")
+	command.Flags().StringSliceVarP(&kd.labels, "labels", "l", []string{}, "List of labels to filter resources (optional)")
+	command.Flags().StringVar(&kd.kubeconfig, "kubeconfig", defaultKubeconfig(), "Path to the kubeconfig file (optional, uses $HOME/.kube/config by default)")
+	command.Flags().BoolVar(&kd.ignoreNonExistent, "ignore-non-existent", false, "Ignore comparison when resource do not exist in one of the contexts (optional)")
+	command.Flags().StringVarP(&kd.output, "output", "o", outputText, "Output format, one of text, json, yaml or html (optional)")
+	command.Flags().StringSliceVar(&kd.failOn, "fail-on", defaultFailOn, "Categories of differences that exit with code 1, any of missing, value, count or none (optional)")
+	command.Flags().StringVar(&kd.baseline, "baseline", "", "Compare every context only against this context (optional)")
+	command.Flags().BoolVar(&kd.consensus, "consensus", false, "Compare every context against the value of the majority of the contexts and only report the outliers (optional)")
+	command.Flags().BoolVar(&kd.continueOnError, "continue-on-error", false, "Report the resources that failed to be read or compared and compare the remaining ones, instead of failing (optional)")
+	command.Flags().StringVar(&kd.rulesFile, "rules", "", "File with the rules of the expected differences, which are reported as suppressed (optional)")
+	command.Flags().StringSliceVar(&kd.namespaceMap, "namespace-map", []string{}, "List of namespace mappings in the format <context>=<namespace>:<namespace>, e.g. staging=payments-stg:payments compares payments-stg in staging with payments (optional)")
+	command.Flags().StringArrayVar(&kd.nameRewrite, "name-rewrite", []string{}, "Name rewrite in the format <context>=<regex>:<replacement>, e.g. staging=^(.*)-stg$:$1 compares api-stg in staging with api, can be repeated (optional)")
 }
 
-// printDifferencesFn is a function that prints the differences between the resources in the contexts
-// m is a map where the key is the resource name and the value is a map where the key is the context and the value is a slice of values being compared
-type printDifferencesFn func(kd *kubediff, namespace string, m map[string]map[string][]any)
-
-// findDifferences finds the differences between the resources in the contexts and prints them using printDiffFunc
-func (kd *kubediff) findDifferences(ctx context.Context, clients map[string]*kubernetes.Clientset, printDifferences printDifferencesFn) error {
-	for _, namespace := range kd.namespaces {
-		for _, resourceType := range kd.resources {
-			log.WithField("namespace", namespace).WithField("resource", resourceType).WithField("path", kd.path).Infoln("Finding differences ...")
-			g, ctx := errgroup.WithContext(ctx)
-			m := make(map[string]map[string][]any)
-			lock := sync.Mutex{}
-			for context, client := range clients {
-				context, namespace, client := context, namespace, client // https://golang.org/doc/faq#closures_and_goroutines
-				g.Go(func() error {
-					funcToApply := func(item any, name string) error {
-						vals, err := getFieldValue(item, kd.path)
-						if err != nil {
-							return logAndReturnErr(namespace, context, resourceType, fmt.Sprintf("Error: failed to get value for path %s: %v", kd.path, err))
-						}
-						switch vals.(type) {
-						case []interface{}:
-							slices, ok := vals.([]interface{})
-							if !ok {
-								return logAndReturnErr(namespace, context, resourceType, "Error: failed to convert to interface slice")
-							}
-							for _, slice := range slices {
-								addToMap(&lock, m, name, context, slice)
-							}
-						default:
-							addToMap(&lock, m, name, context, vals)
-						}
-						return nil
-					}
-
-					err := resource.Apply(ctx, client, resourceType, kd.labels, namespace, funcToApply)
-					if err != nil {
-						return logAndReturnErr(namespace, context, resourceType, fmt.Sprintf("Error: failed to apply func to resource %s: %v", resourceType, err))
-					}
-
-					return nil
-				})
-			}
-			if err := g.Wait(); err != nil {
-				log.Fatal(err)
-				return nil
+// validate validates the flags shared by every command. Contexts ending in .tar.gz or .tgz are snapshot archives.
+// The name of each --source is added to the contexts
+func (kd *kubediff) validate() error {
+	for _, context := range kd.contexts {
+		if source.IsSnapshot(context) {
+			kd.sourceSpecs = append(kd.sourceSpecs, source.Spec{Name: context, Type: source.TypeSnapshot, Location: context})
+			continue
+		}
+		kd.sourceSpecs = append(kd.sourceSpecs, source.Spec{Name: context, Type: source.TypeContext, Location: context})
+	}
+	stdin := false
+	for _, s := range kd.sources {
+		spec, err := source.ParseSpec(s)
+		if err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+		if stringInSlice(spec.Name, kd.contexts) {
+			return fmt.Errorf("Error: context %s is used more than once", spec.Name)
+		}
+		if spec.Type == source.TypeStdin || (spec.Type == source.TypeFile && spec.Location == "-") {
+			if stdin {
+				return fmt.Errorf("Error: stdin can only be used by one source")
 			}
-
-			printDifferences(kd, namespace, m)
+			stdin = true
 		}
+		kd.contexts = append(kd.contexts, spec.Name)
+		kd.sourceSpecs = append(kd.sourceSpecs, spec)
 	}
-	return nil
-}
-
-func logAndReturnErr(namespace, context, resourceType, message string) error {
-	log.
-		WithField("context", context).
-		WithField("namespace", namespace).
-		WithField("resource", resourceType).
-		Error(message)
-	return fmt.Errorf(message)
-}
-
-func printDifferences(kd *kubediff, _ string, m map[string]map[string][]any) {
-	hasDiff := false
-	for resourceName, contextsMap := range m {
-		if processResourceDifferences(kd, resourceName, contextsMap) {
-			hasDiff = true
+	if len(kd.contexts) < 2 {
+		return fmt.Errorf("Error: at least two contexts are required")
+	}
+	if kd.baseline != "" && kd.consensus {
+		return fmt.Errorf("Error: --baseline and --consensus can not be used together")
+	}
+	if kd.baseline != "" && !stringInSlice(kd.baseline, kd.contexts) {
+		return fmt.Errorf("Error: baseline %s is not one of the contexts", kd.baseline)
+	}
+	if err := validateOutput(kd.output); err != nil {
+		return err
+	}
+	if err := validateFailOn(kd.failOn); err != nil {
+		return err
+	}
+	if kd.diffStyle != "" {
+		if err := validateDiffStyle(kd.diffStyle); err != nil {
+			return err
 		}
 	}
-	if !hasDiff {
-		log.Info(color.GreenString("No differences found"))
+	key, err := readHMACKey(kd.hmacKeyFile)
+	if err != nil {
+		return err
+	}
+	kd.hmacKey = key
+	if kd.rulesFile != "" {
+		rules, err := kdiff.LoadRules(kd.rulesFile)
+		if err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+		kd.rules = rules
 	}
+	return kd.parseMappings()
 }
 
-func processResourceDifferences(kd *kubediff, resourceName string, contextsMap map[string][]any) bool {
-	var header, diffStr strings.Builder
-	resourceDiff := false
-	for i, context := range kd.contexts {
-		source, ok := contextsMap[context]
-		if !ok {
-			if !kd.ignoreNonExistent {
-				resourceDiff = true
-				header.WriteString(color.RedString(fmt.Sprintf("\tNot found in %s\n", context)))
-			}
-			continue
+// parseMappings parses --namespace-map and --name-rewrite, both in the format <context>=<from>:<to>
+func (kd *kubediff) parseMappings() error {
+	for _, s := range kd.namespaceMap {
+		context, from, to, err := parseContextMapping(s, kd.contexts)
+		if err != nil {
+			return fmt.Errorf("Error: invalid --namespace-map: %v", err)
 		}
-
-		// compare current context with all other contexts
-		for j := i + 1; j < len(kd.contexts); j++ {
-			targetContext := kd.contexts[j]
-			target, ok := contextsMap[targetContext]
-			if !ok {
-				continue
-			}
-
-			headerDiff, containersDiff, hasDiff := compareElements(context, targetContext, source, target)
-			if hasDiff {
-				resourceDiff = true
-				header.WriteString(headerDiff)
-				diffStr.WriteString(containersDiff)
-			}
+		if kd.namespaceMappings == nil {
+			kd.namespaceMappings = make(map[string]map[string]string)
 		}
+		if kd.namespaceMappings[context] == nil {
+			kd.namespaceMappings[context] = make(map[string]string)
+		}
+		kd.namespaceMappings[context][from] = to
 	}
-	if resourceDiff {
-		log.Warnf("Found differences for %s", color.HiYellowString(resourceName))
-		if str := header.String(); str != "" {
-			fmt.Printf("%s\n", str)
+	for _, s := range kd.nameRewrite {
+		context, pattern, replacement, err := parseContextMapping(s, kd.contexts)
+		if err != nil {
+			return fmt.Errorf("Error: invalid --name-rewrite: %v", err)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("Error: invalid --name-rewrite %q: %v", s, err)
+		}
+		if kd.nameRewrites == nil {
+			kd.nameRewrites = make(map[string][]kdiff.NameRewrite)
 		}
-		fmt.Printf("%s\n", diffStr.String())
+		kd.nameRewrites[context] = append(kd.nameRewrites[context], kdiff.NameRewrite{Pattern: pattern, Replacement: replacement})
 	}
-	return resourceDiff
+	return nil
 }
 
-// Compare elements between two contexts and write differences
-func compareElements(sourceContext, targetContext string, source, target []any) (string, string, bool) {
-	var header, diffStr strings.Builder
-	if len(source) != len(target) {
-		header.WriteString(color.RedString(fmt.Sprintf("\tDifferent number of elements in %s (%d) and %s (%d)\n", sourceContext, len(source), targetContext, len(target))))
-		return header.String(), "", true
+// parseContextMapping parses a mapping in the format <context>=<from>:<to>. The context ends at the first =
+// and to starts after the last :, as names can not have a colon
+func parseContextMapping(s string, contexts []string) (string, string, string, error) {
+	context, mapping, ok := strings.Cut(s, "=")
+	i := strings.LastIndex(mapping, ":")
+	if !ok || context == "" || i <= 0 {
+		return "", "", "", fmt.Errorf("%q must be in the format <context>=<from>:<to>", s)
+	}
+	if !stringInSlice(context, contexts) {
+		return "", "", "", fmt.Errorf("%s is not one of the contexts", context)
 	}
+	return context, mapping[:i], mapping[i+1:], nil
+}
 
-	// Compare the two values
-	diffs := deep.Equal(source, target)
-	if diffs == nil {
-		return "", "", false
+// readHMACKey reads the key used to fingerprint secret values from file or, if file is not set, from
+// the environment. A trailing newline is not part of the key
+func readHMACKey(file string) ([]byte, error) {
+	if file == "" {
+		return []byte(os.Getenv(hmacKeyEnv)), nil
 	}
+	key, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error: failed to read HMAC key: %v", err)
+	}
+	key = bytes.TrimRight(key, "\r\n")
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Error: HMAC key file %s is empty", file)
+	}
+	return key, nil
+}
 
-	diffStr.WriteString(fmt.Sprintf("\tDifference between %s and %s:\n\n", sourceContext, targetContext))
-	for _, diff := range diffs {
-		diffStr.WriteString(fmt.Sprintf("\t\t%v\n", diff))
+// newDiffer creates a differ that compares the paths of the resources selected by the flags. The
+// source of each context is created using the kubeconfig file provided by --kubeconfig
+func (kd *kubediff) newDiffer(paths ...string) (*kdiff.Differ, error) {
+	sources := make(map[string]kdiff.Source, len(kd.sourceSpecs))
+	for _, spec := range kd.sourceSpecs {
+		if spec.Type == source.TypeContext && kd.kubeconfig == "" {
+			return nil, fmt.Errorf("Error: kubeconfig not set, use --kubeconfig")
+		}
+		src, err := source.New(spec, kd.kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("Error: failed to create source %s: %v", spec.Name, err)
+		}
+		sources[spec.Name] = src
 	}
-	diffStr.WriteString("\n")
-	return header.String(), diffStr.String(), true
+	return &kdiff.Differ{
+		Contexts: kd.contexts,
+		Sources:  sources,
+		Selector: kdiff.Selector{
+			Resources:  kd.resources,
+			Namespaces: kd.namespaces,
+			Labels:     kd.labels,
+		},
+		Paths: paths,
+		Options: kdiff.Options{
+			IgnoreNonExistent: kd.ignoreNonExistent,
+			Baseline:          kd.baseline,
+			Consensus:         kd.consensus,
+			ContinueOnError:   kd.continueOnError,
+			HMACKey:           kd.hmacKey,
+			Rules:             kd.rules,
+			NamespaceMappings: kd.namespaceMappings,
+			NameRewrites:      kd.nameRewrites,
+		},
+		Logger: log.StandardLogger(),
+	}, nil
 }
 
-func getFieldValue(data interface{}, path string) (interface{}, error) {
-	parts := strings.Split(path, ".")
-	val := reflect.ValueOf(data)
+// diff runs the differ, prints the report using printText for the text output and returns ErrDrift if
+// there are differences in the --fail-on categories. With --continue-on-error the results are printed
+// even if some resources failed, but an error is still returned because they are incomplete
+func (kd *kubediff) diff(ctx context.Context, differ *kdiff.Differ, printText printTextFn) error {
+	report, err := differ.Diff(ctx)
+	if err != nil {
+		return err
+	}
+	if err := kd.printResults(ctx, report, differ.Sources, printText); err != nil {
+		return err
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("Error: failed to compare some resources, the results are incomplete")
+	}
+	return kd.checkDrift(report)
+}
 
-	for i, part := range parts {
-		// Dereference pointer if necessary
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
-		}
+// defaultKubeconfig returns $HOME/.kube/config or an empty string if the home directory is unknown, in which
+// case --kubeconfig is required to compare contexts
+func defaultKubeconfig() string {
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
 
-		if strings.HasSuffix(part, "[*]") {
-			// Handle the slice iteration
-			fieldName := strings.TrimSuffix(part, "[*]")
-			val = val.FieldByName(fieldName)
-			if val.Kind() != reflect.Slice {
-				return nil, fmt.Errorf("field '%s' is not a slice", fieldName)
+// printDifferences prints the differences of each resource grouped by pair of contexts, as a list of fields or
+// as the line diff of the values with --diff-style
+func printDifferences(kd *kubediff, results []kdiff.ResourceResult) {
+	if len(results) == 0 {
+		log.Info(color.GreenString("No differences found"))
+		return
+	}
+	for _, result := range results {
+		// the header is part of the results, so it is printed to stdout with them
+		fmt.Printf("Found differences for %s%s\n", color.HiYellowString(result.String()), formatNames(kd.contexts, result.Names))
+		if len(result.Missing) > 0 {
+			var header strings.Builder
+			for _, context := range result.Missing {
+				header.WriteString(color.RedString(fmt.Sprintf("\tNot found in %s\n", context)))
 			}
+			fmt.Printf("%s\n", header.String())
+		}
 
-			// Handle the remaining path for each slice element
-			remainingPath := strings.Join(parts[i+1:], ".")
-			results := []interface{}{}
-			for j := 0; j < val.Len(); j++ {
-				item := val.Index(j).Interface()
-				if remainingPath != "" {
-					// Recursively process the remaining path
-					result, err := getFieldValue(item, remainingPath)
-					if err != nil {
-						return nil, err
+		var diffStr strings.Builder
+		lineDiff := ""
+		for i, d := range result.Differences {
+			if i == 0 || result.Differences[i-1].SourceContext != d.SourceContext || result.Differences[i-1].TargetContext != d.TargetContext {
+				if i > 0 {
+					diffStr.WriteString("\n")
+				}
+				diffStr.WriteString(fmt.Sprintf("\tDifference between %s and %s:\n\n", d.SourceContext, d.TargetContext))
+				lineDiff = ""
+				// the line diff of the values would also show the differences suppressed by the rules, so the
+				// pairs with suppressed differences list the fields instead
+				if (kd.diffStyle == diffStyleUnified || kd.diffStyle == diffStyleSideBySide) && !hasSuppressed(kd.suppressed, result, d.SourceContext, d.TargetContext) {
+					var err error
+					if lineDiff, err = formatPairDiff(kd.diffStyle, result, d.SourceContext, d.TargetContext); err != nil {
+						log.Warnf("Failed to print the line diff of %s: %v", result.String(), err)
 					}
-					results = append(results, result)
-				} else {
-					results = append(results, item)
+					diffStr.WriteString(lineDiff)
 				}
 			}
-			return results, nil
+			// the line diff has every difference of the pair, except the values that must differ but are equal
+			if lineDiff == "" || d.Type == kdiff.Unchanged {
+				diffStr.WriteString(fmt.Sprintf("\t\t%s\n", formatDifference(d.Change, d.SourceContext, d.TargetContext)))
+			}
 		}
-		// Normal struct field access
-		val = val.FieldByName(part)
-		if !val.IsValid() {
-			return nil, fmt.Errorf("field '%s' not found", part)
+		if diffStr.Len() > 0 {
+			fmt.Printf("%s\n", diffStr.String())
 		}
+	}
+}
 
+// formatNames returns the names of a resource in the contexts where it is mapped or renamed, e.g.
+// " (payments-stg/api-stg in staging)"
+func formatNames(contexts []string, names map[string]string) string {
+	var parts []string
+	for _, context := range contexts {
+		if name, ok := names[context]; ok {
+			parts = append(parts, fmt.Sprintf("%s in %s", name, context))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
 	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
 
-	return val.Interface(), nil
+// formatDifference returns a human readable description of a difference
+func formatDifference(d kdiff.Change, sourceContext, targetContext string) string {
+	path := d.Path
+	if path == "" {
+		path = "value"
+	}
+	switch d.Type {
+	case kdiff.Removed:
+		return fmt.Sprintf("%s: %s", path, color.RedString("missing in %s", targetContext))
+	case kdiff.Added:
+		return fmt.Sprintf("%s: %s", path, color.RedString("missing in %s", sourceContext))
+	case kdiff.Unchanged:
+		return fmt.Sprintf("%s: %s", path, color.RedString("expected to differ between %s and %s", sourceContext, targetContext))
+	default:
+		if lineDiff := formatLineDiff(d.Source, d.Target); lineDiff != "" {
+			return fmt.Sprintf("%s:\n%s", path, lineDiff)
+		}
+		return fmt.Sprintf("%s: %v != %v", path, d.Source, d.Target)
+	}
 }
 
-// addToMap is a helper function that adds a value to a map m with key resourceName and context
-func addToMap(lock *sync.Mutex, m map[string]map[string][]any, resourceName, context string, val any) {
-	lock.Lock()
-	defer lock.Unlock()
-	if _, ok := m[resourceName]; !ok {
-		m[resourceName] = make(map[string][]any)
-	}
-	m[resourceName][context] = append(m[resourceName][context], val)
+// formatLineDiff returns the unified line diff of multi-line strings, e.g. embedded YAML or properties files.
+// Returns an empty string for other values
+func formatLineDiff(source, target any) string {
+	s, ok := source.(string)
+	if !ok {
+		return ""
+	}
+	t, ok := target.(string)
+	if !ok || (!strings.Contains(s, "\n") && !strings.Contains(t, "\n")) {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range diff.SplitLines(diff.Unified(s, t, 3)) {
+		switch {
+		case strings.HasPrefix(line, string(diff.Delete)):
+			line = color.RedString(line)
+		case strings.HasPrefix(line, string(diff.Insert)):
+			line = color.GreenString(line)
+		case strings.HasPrefix(line, "@@"):
+			line = color.CyanString(line)
+		}
+		sb.WriteString(fmt.Sprintf("\t\t\t%s\n", line))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestCommonFlags(t *testing.T) {
+	root := Newkubediff()
+	commands := []*cobra.Command{NewImages(), NewEnvs(), NewConfigMaps(), NewSecrets(), NewResources(), NewReplicas(), NewProbes()}
+	common := &cobra.Command{}
+	addCommonFlags(common, &kubediff{})
+	for _, command := range append(commands, root) {
+		common.Flags().VisitAll(func(want *pflag.Flag) {
+			flag := command.Flags().Lookup(want.Name)
+			if flag == nil {
+				t.Errorf("%s: flag --%s not found", command.Name(), want.Name)
+				return
+			}
+			if flag.Usage != want.Usage || flag.DefValue != want.DefValue {
+				t.Errorf("%s: flag --%s = %q (default %q), want %q (default %q)", command.Name(), want.Name, flag.Usage, flag.DefValue, want.Usage, want.DefValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// probePathRegexp matches the paths of the differences of kdiff.CompareProbes, e.g.
+// containers[name=app].livenessProbe.timeoutSeconds
+var probePathRegexp = regexp.MustCompile(`^containers\[name=([^\]]*)\](?:\.([^.]+)(?:\.([^.]+))?)?$`)
+
+func NewProbes() *cobra.Command {
+	kd := &kubediff{
+		resources: []string{"deployment", "statefulset", "daemonset"},
+	}
+	command := &cobra.Command{
+		Use:   "probes",
+		Short: "Detect different container probes and lifecycle hooks between Kubernetes clusters",
+		Long: `A CLI tool to detect differences between the liveness, readiness and startup probes and the postStart and
+		preStop lifecycle hooks of the containers of deployments, daemonsets or statefulsets with the same name in Kubernetes clusters`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer("spec.template.spec.containers[*]", "spec.template.spec.initContainers[*]")
+			if err != nil {
+				return err
+			}
+			differ.Resolve = kdiff.ResolveProbes
+			differ.Compare = kdiff.CompareProbes
+			return kd.diff(cmd.Context(), differ, printProbesDifferences)
+		},
+	}
+
+	addCommonFlags(command, kd)
+	return command
+}
+
+// printProbesDifferences prints a table with a row for each probe or lifecycle hook field with differences
+// and a column with its value in each context. A probe that only exists in some contexts has a row for each field
+func printProbesDifferences(kd *kubediff, results []kdiff.ResourceResult) {
+	if len(results) == 0 {
+		log.Infoln(color.GreenString("No differences found"))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	var header strings.Builder
+	header.WriteString("Name\tNamespace\tContainer\tProbe\tField\t")
+	for _, context := range kd.contexts {
+		header.WriteString(context)
+		header.WriteString("\t")
+	}
+	var sb strings.Builder
+	missing := make(map[string]bool)
+	for _, result := range results {
+		if len(result.Differences) == 0 {
+			// the resource was not found in some contexts, there is a result for each path
+			if missing[result.String()] {
+				continue
+			}
+			missing[result.String()] = true
+			sb.WriteString(fmt.Sprintf("%s\t%s\t\t\t\t", result.Name, result.Namespace))
+			for _, context := range kd.contexts {
+				if stringInSlice(context, result.Missing) {
+					sb.WriteString("not found")
+				}
+				sb.WriteString("\t")
+			}
+			sb.WriteString("\n")
+			continue
+		}
+		printed := make(map[string]bool)
+		for _, d := range result.Differences {
+			matches := probePathRegexp.FindStringSubmatch(d.Path)
+			if matches == nil {
+				continue
+			}
+			container, probe := matches[1], matches[2]
+			fields := []string{matches[3]}
+			if probe != "" && matches[3] == "" {
+				fields = probeFields(kd, result, container, probe)
+			}
+			for _, field := range fields {
+				row := strings.Join([]string{container, probe, field}, ".")
+				if printed[row] {
+					continue
+				}
+				printed[row] = true
+				sb.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t", result.Name, result.Namespace, container, dash(probe), dash(field)))
+				for _, context := range kd.contexts {
+					sb.WriteString(probeSetting(result, context, container, probe, field))
+					sb.WriteString("\t")
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, header.String())
+	fmt.Fprintln(w, sb.String())
+	w.Flush()
+}
+
+// probeFields returns the sorted fields of a probe or lifecycle hook of a container in every context
+func probeFields(kd *kubediff, result kdiff.ResourceResult, container, probe string) []string {
+	set := make(map[string]bool)
+	for _, context := range kd.contexts {
+		if settings, ok := containerSettings(result, context, container)[probe].(map[string]any); ok {
+			for field := range settings {
+				set[field] = true
+			}
+		}
+	}
+	fields := make([]string, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// probeSetting returns the value of a field of a probe or lifecycle hook of a container in a context, or a
+// description of why there is none
+func probeSetting(result kdiff.ResourceResult, context, container, probe, field string) string {
+	if stringInSlice(context, result.Missing) {
+		return "not found"
+	}
+	settings := containerSettings(result, context, container)
+	switch {
+	case settings == nil:
+		return "no container"
+	case probe == "":
+		return "found"
+	}
+	probeSettings, ok := settings[probe].(map[string]any)
+	if !ok {
+		return "none"
+	}
+	value, ok := probeSettings[field]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprint(value)
+}
+
+// containerSettings returns the probes and lifecycle hooks of a container in a context, resolved by
+// kdiff.ResolveProbes
+func containerSettings(result kdiff.ResourceResult, context, container string) map[string]any {
+	for _, val := range result.Values[context] {
+		if settings, ok := val.(map[string]any); ok && settings["name"] == container {
+			return settings
+		}
+	}
+	return nil
+}
+
+// dash returns s or - if it is empty
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// configFile is the name of the configuration file looked up in the current and home directories
+const configFile = ".kubediff.yaml"
+
+// config is the configuration file. Each profile sets flags by their long name, e.g. contexts or ignore-env
+type config struct {
+	Profiles map[string]map[string]any `json:"profiles"`
+}
+
+// addProfileFlags adds the flags that select a profile to command and every subcommand
+func addProfileFlags(command *cobra.Command) {
+	command.PersistentFlags().String("config", "", "Path to the configuration file, defaults to ./"+configFile+" or $HOME/"+configFile+" (optional)")
+	command.PersistentFlags().String("profile", "", "Name of the profile of the configuration file whose settings are used as flags, flags set in the command line take precedence (optional)")
+	command.PersistentPreRunE = applyProfile
+}
+
+// applyProfile sets the flags of cmd that are not set in the command line to the values of the profile. Settings
+// that are flags of other commands are ignored, so a profile can be shared by several commands
+func applyProfile(cmd *cobra.Command, _ []string) error {
+	path, _ := cmd.Flags().GetString("config")
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		return nil
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("Error: profile %s not found", name)
+	}
+
+	known := knownFlags(cmd.Root())
+	settings := make([]string, 0, len(profile))
+	for setting := range profile {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	for _, setting := range settings {
+		if setting == "config" || setting == "profile" || !known[setting] {
+			return fmt.Errorf("Error: profile %s has an unknown setting %s", name, setting)
+		}
+		flag := cmd.Flags().Lookup(setting)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if err := setFlag(flag, profile[setting]); err != nil {
+			return fmt.Errorf("Error: invalid setting %s of profile %s: %v", setting, name, err)
+		}
+	}
+	return nil
+}
+
+// loadConfig loads the configuration file of path or, if path is empty, of the current or home directory
+func loadConfig(path string) (*config, error) {
+	paths := []string{path}
+	if path == "" {
+		paths = []string{configFile, filepath.Join(homedir.HomeDir(), configFile)}
+	}
+	for _, p := range paths {
+		content, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error: failed to read configuration file: %v", err)
+		}
+		cfg := &config{}
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return nil, fmt.Errorf("Error: invalid configuration file %s: %v", p, err)
+		}
+		return cfg, nil
+	}
+	return nil, fmt.Errorf("Error: configuration file %s not found, use --config", configFile)
+}
+
+// knownFlags returns the long names of the flags of command and of its subcommands
+func knownFlags(command *cobra.Command) map[string]bool {
+	known := make(map[string]bool)
+	command.Flags().VisitAll(func(flag *pflag.Flag) {
+		known[flag.Name] = true
+	})
+	for _, sub := range command.Commands() {
+		for name := range knownFlags(sub) {
+			known[name] = true
+		}
+	}
+	return known
+}
+
+// setFlag sets a flag to a setting of a profile. Lists set list flags, e.g. contexts: [staging, production],
+// and every other value is parsed as in the command line
+func setFlag(flag *pflag.Flag, value any) error {
+	list, isList := value.([]any)
+	sliceValue, isSlice := flag.Value.(pflag.SliceValue)
+	switch {
+	case isList && isSlice:
+		values := make([]string, 0, len(list))
+		for _, v := range list {
+			values = append(values, fmt.Sprint(v))
+		}
+		if err := sliceValue.Replace(values); err != nil {
+			return err
+		}
+	case isList:
+		return fmt.Errorf("expected a single value")
+	default:
+		if err := flag.Value.Set(fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+	flag.Changed = true
+	return nil
+}
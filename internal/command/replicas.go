@@ -0,0 +1,36 @@
+package commands
+
+import (
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/spf13/cobra"
+)
+
+func NewReplicas() *cobra.Command {
+	kd := &kubediff{
+		resources: []string{"deployment", "statefulset"},
+	}
+	command := &cobra.Command{
+		Use:   "replicas",
+		Short: "Detect different replicas, autoscalers and disruption budgets between Kubernetes clusters",
+		Long: `A CLI tool to detect differences between the replicas of deployments or statefulsets with the same name in
+		Kubernetes clusters, together with the horizontalpodautoscalers targeting them and the poddisruptionbudgets
+		selecting their pods. Replicas are not compared when a horizontalpodautoscaler targets the workload`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer("spec")
+			if err != nil {
+				return err
+			}
+			differ.Resolve = kdiff.ResolveScaling()
+			differ.Compare = kdiff.CompareScaling
+			return kd.diff(cmd.Context(), differ, printDifferences)
+		},
+	}
+
+	addCommonFlags(command, kd)
+	return command
+}
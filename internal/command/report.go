@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+	outputHTML = "html"
+)
+
+// printTextFn is a function that prints the results in a human readable format
+type printTextFn func(kd *kubediff, results []kdiff.ResourceResult)
+
+func validateOutput(output string) error {
+	switch output {
+	case outputText, outputJSON, outputYAML, outputHTML:
+		return nil
+	}
+	return fmt.Errorf("Error: unsupported output %q, must be one of %s, %s, %s or %s", output, outputText, outputJSON, outputYAML, outputHTML)
+}
+
+// printResults prints the report to stdout in the output format, using printText for the text format. The
+// sources of the contexts are used by the html format to get the server versions
+func (kd *kubediff) printResults(ctx context.Context, report *kdiff.Report, sources map[string]kdiff.Source, printText printTextFn) error {
+	switch kd.output {
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode report: %v", err)
+		}
+	case outputYAML:
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %v", err)
+		}
+		os.Stdout.Write(data)
+	case outputHTML:
+		if err := kd.printHTML(ctx, os.Stdout, report, sources); err != nil {
+			return fmt.Errorf("failed to write report: %v", err)
+		}
+	default:
+		kd.suppressed = report.Suppressed
+		printText(kd, report.Resources)
+		printSuppressed(report.Suppressed)
+		printErrors(report.Errors)
+	}
+	return nil
+}
+
+// printErrors logs the errors that prevented the comparison of some resources
+func printErrors(errs []kdiff.ResourceError) {
+	for _, err := range errs {
+		entry := log.WithField("resource", err.Resource).WithField("path", err.Path)
+		if err.Context != "" {
+			entry = entry.WithField("context", err.Context)
+		}
+		if err.Namespace != "" {
+			entry = entry.WithField("namespace", err.Namespace)
+		}
+		if err.Name != "" {
+			entry = entry.WithField("name", err.Name)
+		}
+		entry.Error(err.Message)
+	}
+}
+
+// printSuppressed prints a table with the differences suppressed by the rules, so expected differences are
+// still visible
+func printSuppressed(results []kdiff.ResourceResult) {
+	if len(results) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(w, "Suppressed differences:")
+	fmt.Fprintln(w, "Resource\tPath\tField\tContexts\tDifference\tRule\t")
+	for _, result := range results {
+		for _, d := range result.Differences {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s, %s\t%s\t%s\t\n", result.String(), result.Path, dash(d.Path), d.SourceContext, d.TargetContext, describeChange(d.Change), d.Rule)
+		}
+	}
+	fmt.Fprintln(w)
+	w.Flush()
+}
+
+// describeChange returns a single line description of a change
+func describeChange(d kdiff.Change) string {
+	switch d.Type {
+	case kdiff.Removed:
+		return fmt.Sprintf("only in source: %s", oneLine(d.Source))
+	case kdiff.Added:
+		return fmt.Sprintf("only in target: %s", oneLine(d.Target))
+	}
+	return fmt.Sprintf("%s != %s", oneLine(d.Source), oneLine(d.Target))
+}
+
+// oneLine formats a value in a single line, multi-line strings are quoted
+func oneLine(v any) string {
+	s := fmt.Sprint(v)
+	if strings.Contains(s, "\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// resourcePathRegexp matches the paths of the differences of kdiff.CompareContainerResources, e.g.
+// containers[name=app].resources.limits.cpu
+var resourcePathRegexp = regexp.MustCompile(`^containers\[name=([^\]]*)\](?:\.resources\.(requests|limits)\.(.+))?$`)
+
+func NewResources() *cobra.Command {
+	var tolerance float64
+	kd := &kubediff{
+		resources: []string{"deployment", "statefulset", "daemonset"},
+	}
+	command := &cobra.Command{
+		Use:   "resources",
+		Short: "Detect different container requests and limits between Kubernetes clusters",
+		Long: `A CLI tool to detect differences between the cpu, memory and ephemeral-storage requests and limits of the
+		containers of deployments, daemonsets or statefulsets with the same name in Kubernetes clusters`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
+			}
+			if tolerance < 0 || tolerance > 100 {
+				return fmt.Errorf("Error: --tolerance must be a percentage between 0 and 100")
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer("spec.template.spec.containers[*]", "spec.template.spec.initContainers[*]")
+			if err != nil {
+				return err
+			}
+			differ.Compare = kdiff.CompareContainerResources(tolerance)
+			return kd.diff(cmd.Context(), differ, printResourcesDifferences)
+		},
+	}
+
+	addCommonFlags(command, kd)
+	command.Flags().Float64Var(&tolerance, "tolerance", 0, "Percentage of difference between quantities that is not reported, e.g. 10 ignores 950m != 1 (optional)")
+	return command
+}
+
+// printResourcesDifferences prints a table with a row for each container request or limit with differences
+// and a column with its value in each context
+func printResourcesDifferences(kd *kubediff, results []kdiff.ResourceResult) {
+	if len(results) == 0 {
+		log.Infoln(color.GreenString("No differences found"))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	var header strings.Builder
+	header.WriteString("Name\tNamespace\tContainer\tResource\t")
+	for _, context := range kd.contexts {
+		header.WriteString(context)
+		header.WriteString("\t")
+	}
+	var sb strings.Builder
+	missing := make(map[string]bool)
+	for _, result := range results {
+		if len(result.Differences) == 0 {
+			// the resource was not found in some contexts, there is a result for each path
+			if missing[result.String()] {
+				continue
+			}
+			missing[result.String()] = true
+			sb.WriteString(fmt.Sprintf("%s\t%s\t\t\t", result.Name, result.Namespace))
+			for _, context := range kd.contexts {
+				if stringInSlice(context, result.Missing) {
+					sb.WriteString("not found")
+				}
+				sb.WriteString("\t")
+			}
+			sb.WriteString("\n")
+			continue
+		}
+		printed := make(map[string]bool)
+		for _, d := range result.Differences {
+			matches := resourcePathRegexp.FindStringSubmatch(d.Path)
+			if matches == nil || printed[d.Path] {
+				continue
+			}
+			printed[d.Path] = true
+			container, list, name := matches[1], matches[2], matches[3]
+			resourceName := "-"
+			if list != "" {
+				resourceName = list + "." + name
+			}
+			sb.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t", result.Name, result.Namespace, container, resourceName))
+			for _, context := range kd.contexts {
+				sb.WriteString(containerQuantity(result, context, container, list, name))
+				sb.WriteString("\t")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, header.String())
+	fmt.Fprintln(w, sb.String())
+	w.Flush()
+}
+
+// containerQuantity returns the quantity of a request or limit of a container in a context, as defined
+// in the resource, or a description of why there is none
+func containerQuantity(result kdiff.ResourceResult, context, container, list, name string) string {
+	if stringInSlice(context, result.Missing) {
+		return "not found"
+	}
+	vals, ok := result.Values[context]
+	if !ok {
+		return ""
+	}
+	for _, val := range vals {
+		c, ok := val.(map[string]any)
+		if !ok || c["name"] != container {
+			continue
+		}
+		if list == "" {
+			return "found"
+		}
+		resources, _ := c["resources"].(map[string]any)
+		quantities, _ := resources[list].(map[string]any)
+		if quantity, ok := quantities[name]; ok {
+			return fmt.Sprint(quantity)
+		}
+		return "-"
+	}
+	return "no container"
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	"github.com/spf13/cobra"
+)
+
+func NewSecrets() *cobra.Command {
+	kd := &kubediff{
+		resources: []string{"secret"},
+	}
+	command := &cobra.Command{
+		Use:   "secrets",
+		Short: "Detect different secret keys and values between Kubernetes clusters without revealing the values",
+		Long: `A CLI tool to detect added, removed and changed keys in secrets with the same name in Kubernetes clusters.
+		Values are compared by their SHA-256 fingerprint, or HMAC-SHA256 when a key is provided, and are never printed`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := kd.validate(); err != nil {
+				return err
+			}
+			if err := validateIgnoreKeys(kd.ignoreKeys); err != nil {
+				return err
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			differ, err := kd.newDiffer("data")
+			if err != nil {
+				return err
+			}
+			// secrets are redacted by the differ, data only has the fingerprints of the values
+			differ.Compare = kdiff.CompareDataKeys(kd.ignoreKeys)
+			return kd.diff(cmd.Context(), differ, printDifferences)
+		},
+	}
+
+	addCommonFlags(command, kd)
+	command.Flags().StringSliceVarP(&kd.ignoreKeys, "ignore-key", "i", []string{}, "List of key patterns to ignore when comparing data, e.g. *.crt (optional)")
+	command.Flags().StringVar(&kd.hmacKeyFile, "hmac-key-file", "", "File with the key used to fingerprint secret values with HMAC-SHA256, defaults to $"+hmacKeyEnv+" (optional)")
+	return command
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/eduardodbr/kubediff/internal/source"
+	kdiff "github.com/eduardodbr/kubediff/pkg/kubediff"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func NewSnapshot() *cobra.Command {
+	var kubeconfig, context, output, hmacKeyFile string
+	var resources, namespaces, labels []string
+	command := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Capture the resources of a Kubernetes cluster into a snapshot archive",
+		Long: `A CLI tool to capture the selected resources of a context into a versioned snapshot archive that can be used
+		instead of a context, e.g. kubediff -c prod,prod-2026-10-01.tar.gz. Secret values are replaced by their fingerprints`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !source.IsSnapshot(output) {
+				return fmt.Errorf("Error: output %s must end in .tar.gz or .tgz", output)
+			}
+			key, err := readHMACKey(hmacKeyFile)
+			if err != nil {
+				return err
+			}
+			// flags are valid, errors from here on are not usage errors
+			cmd.SilenceUsage = true
+			if kubeconfig == "" {
+				return fmt.Errorf("Error: kubeconfig not set, use --kubeconfig")
+			}
+			src, err := kdiff.NewContextSource(kubeconfig, context)
+			if err != nil {
+				return fmt.Errorf("Error: failed to create source %s: %v", context, err)
+			}
+			// the archive is written to a temporary file so a failed snapshot does not leave a partial archive
+			f, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+			if err != nil {
+				return fmt.Errorf("Error: failed to create snapshot: %v", err)
+			}
+			defer os.Remove(f.Name())
+			selector := kdiff.Selector{Resources: resources, Namespaces: namespaces, Labels: labels}
+			if err := kdiff.WriteSnapshot(cmd.Context(), f, context, src, selector, key); err != nil {
+				f.Close()
+				return fmt.Errorf("Error: failed to create snapshot: %v", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("Error: failed to create snapshot: %v", err)
+			}
+			if err := os.Rename(f.Name(), output); err != nil {
+				return fmt.Errorf("Error: failed to create snapshot: %v", err)
+			}
+			log.Infof("Snapshot of %s written to %s", context, output)
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&context, "context", "", "Context to capture (mandatory)")
+	command.Flags().StringVarP(&output, "output", "o", "", "Path of the snapshot archive, ending in .tar.gz or .tgz (mandatory)")
+	command.Flags().StringSliceVarP(&resources, "resources", "r", []string{}, "List of resources to capture (mandatory)")
+	command.Flags().StringSliceVarP(&namespaces, "namespaces", "n", []string{""}, "List of namespaces (optional)")
+	command.Flags().StringSliceVarP(&labels, "labels", "l", []string{}, "List of labels to filter resources (optional)")
+	command.Flags().StringVar(&kubeconfig, "kubeconfig", defaultKubeconfig(), "Path to the kubeconfig file")
+	command.Flags().StringVar(&hmacKeyFile, "hmac-key-file", "", "File with the key used to fingerprint secret values with HMAC-SHA256, defaults to $"+hmacKeyEnv+" (optional)")
+	command.MarkFlagRequired("context")
+	command.MarkFlagRequired("output")
+	command.MarkFlagRequired("resources")
+	return command
+}
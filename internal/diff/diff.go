@@ -0,0 +1,273 @@
+// Package diff implements the structural comparison of unstructured values
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Type is the type of a difference
+type Type string
+
+const (
+	// Changed means the value exists in both source and target with different values
+	Changed Type = "changed"
+	// Removed means the value only exists in source
+	Removed Type = "removed"
+	// Added means the value only exists in target
+	Added Type = "added"
+	// Unchanged means the value is equal in source and target, it is only reported when the values are
+	// expected to differ
+	Unchanged Type = "unchanged"
+)
+
+// Difference is a difference found between the source and the target values
+type Difference struct {
+	Type Type `json:"type"`
+	// Path is the path of the value, list elements matched by key are identified by their
+	// key (e.g. containers[name=app].image) and the remaining by their index (e.g. args[0])
+	Path   string `json:"path"`
+	Source any    `json:"source,omitempty"`
+	Target any    `json:"target,omitempty"`
+}
+
+// listKeys maps the name of a list field to the candidate fields that identify its elements.
+// The first candidate present in every element is used
+var listKeys = map[string][][]string{
+	"containers":     {{"name"}},
+	"initContainers": {{"name"}},
+	"env":            {{"name"}},
+	"ports":          {{"containerPort", "protocol"}, {"port", "protocol"}},
+	"volumes":        {{"name"}},
+}
+
+// keyDefaults are the values of key fields that may be omitted because the API server defaults them
+var keyDefaults = map[string]any{
+	"protocol": "TCP",
+}
+
+// Compare compares source and target and returns the differences. Lists with a known key (see listKeys)
+// or whose elements all have a unique name are matched by key, every other list is matched by index
+func Compare(source, target any) []Difference {
+	return compare("", "", normalize(source), normalize(target))
+}
+
+func compare(path, field string, source, target any) []Difference {
+	switch s := source.(type) {
+	case map[string]any:
+		if t, ok := target.(map[string]any); ok {
+			return compareMaps(path, s, t)
+		}
+	case []any:
+		if t, ok := target.([]any); ok {
+			return compareLists(path, field, s, t)
+		}
+	default:
+		if equalScalars(source, target) {
+			return nil
+		}
+	}
+	return []Difference{{Type: Changed, Path: path, Source: source, Target: target}}
+}
+
+func compareMaps(path string, source, target map[string]any) []Difference {
+	keys := make([]string, 0, len(source)+len(target))
+	for key := range source {
+		keys = append(keys, key)
+	}
+	for key := range target {
+		if _, ok := source[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var diffs []Difference
+	for _, key := range keys {
+		keyPath := joinPath(path, key)
+		s, inSource := source[key]
+		t, inTarget := target[key]
+		switch {
+		case !inTarget:
+			diffs = append(diffs, Difference{Type: Removed, Path: keyPath, Source: s})
+		case !inSource:
+			diffs = append(diffs, Difference{Type: Added, Path: keyPath, Target: t})
+		default:
+			diffs = append(diffs, compare(keyPath, key, s, t)...)
+		}
+	}
+	return diffs
+}
+
+func compareLists(path, field string, source, target []any) []Difference {
+	keys := matchingKeys(field, source, target)
+	if keys == nil {
+		return compareListsByIndex(path, source, target)
+	}
+
+	sourceByKey := make(map[string]any, len(source))
+	for _, item := range source {
+		sourceByKey[elementKey(item, keys)] = item
+	}
+	targetByKey := make(map[string]any, len(target))
+	for _, item := range target {
+		targetByKey[elementKey(item, keys)] = item
+	}
+
+	var diffs []Difference
+	// keep the order of the source followed by the elements only found in target
+	for _, item := range source {
+		key := elementKey(item, keys)
+		elementPath := path + "[" + key + "]"
+		t, ok := targetByKey[key]
+		if !ok {
+			diffs = append(diffs, Difference{Type: Removed, Path: elementPath, Source: item})
+			continue
+		}
+		diffs = append(diffs, compare(elementPath, "", item, t)...)
+	}
+	for _, item := range target {
+		key := elementKey(item, keys)
+		if _, ok := sourceByKey[key]; !ok {
+			diffs = append(diffs, Difference{Type: Added, Path: path + "[" + key + "]", Target: item})
+		}
+	}
+	return diffs
+}
+
+func compareListsByIndex(path string, source, target []any) []Difference {
+	var diffs []Difference
+	for i := 0; i < len(source) || i < len(target); i++ {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(target):
+			diffs = append(diffs, Difference{Type: Removed, Path: elementPath, Source: source[i]})
+		case i >= len(source):
+			diffs = append(diffs, Difference{Type: Added, Path: elementPath, Target: target[i]})
+		default:
+			diffs = append(diffs, compare(elementPath, "", source[i], target[i])...)
+		}
+	}
+	return diffs
+}
+
+// matchingKeys returns the fields that identify the elements of both lists or nil if the lists must
+// be compared by index
+func matchingKeys(field string, source, target []any) []string {
+	for _, keys := range listKeys[field] {
+		if uniqueKeys(keys, source) && uniqueKeys(keys, target) {
+			return keys
+		}
+	}
+	if len(source) > 0 && len(target) > 0 && uniqueKeys([]string{"name"}, source) && uniqueKeys([]string{"name"}, target) {
+		return []string{"name"}
+	}
+	return nil
+}
+
+// uniqueKeys checks if every element is a map with the key fields and no two elements share the same key
+func uniqueKeys(keys []string, items []any) bool {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		for _, key := range keys {
+			if _, ok := m[key]; !ok && keyDefaults[key] == nil {
+				return false
+			}
+		}
+		key := elementKey(item, keys)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
+
+// elementKey returns the key of a list element, e.g. name=app or containerPort=80,protocol=TCP
+func elementKey(item any, keys []string) string {
+	m, _ := item.(map[string]any)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, ok := m[key]
+		if !ok {
+			value = keyDefaults[key]
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// equalScalars compares two scalar values, numbers are compared by value regardless of their type
+func equalScalars(source, target any) bool {
+	if s, ok := toFloat(source); ok {
+		if t, ok := toFloat(target); ok {
+			return s == t
+		}
+	}
+	return reflect.DeepEqual(source, target)
+}
+
+func toFloat(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// normalize converts typed values to their unstructured representation so every value is a map[string]any,
+// a []any or a scalar
+func normalize(val any) any {
+	switch v := val.(type) {
+	case nil, string, bool, int, int32, int64, float32, float64, json.Number:
+		return v
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = normalize(value)
+		}
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, value := range v {
+			l[i] = normalize(value)
+		}
+		return l
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return val
+	}
+	var out any
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&out); err != nil {
+		return val
+	}
+	return normalize(out)
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		source any
+		target any
+		want   []Difference
+	}{
+		{
+			name:   "equal maps",
+			source: map[string]any{"a": "x", "b": []any{"y"}},
+			target: map[string]any{"b": []any{"y"}, "a": "x"},
+		},
+		{
+			name:   "changed, removed and added fields are sorted by path",
+			source: map[string]any{"b": "x", "a": "removed"},
+			target: map[string]any{"b": "y", "c": "added"},
+			want: []Difference{
+				{Type: Removed, Path: "a", Source: "removed"},
+				{Type: Changed, Path: "b", Source: "x", Target: "y"},
+				{Type: Added, Path: "c", Target: "added"},
+			},
+		},
+		{
+			name:   "numbers are compared by value",
+			source: map[string]any{"replicas": int64(2), "cpu": float64(0.5), "port": json.Number("80")},
+			target: map[string]any{"replicas": float64(2), "cpu": float32(0.5), "port": int32(80)},
+		},
+		{
+			name:   "numbers and strings are different",
+			source: map[string]any{"port": int64(80)},
+			target: map[string]any{"port": "80"},
+			want:   []Difference{{Type: Changed, Path: "port", Source: int64(80), Target: "80"}},
+		},
+		{
+			name:   "a value and a map are different",
+			source: map[string]any{"a": "x"},
+			target: map[string]any{"a": map[string]any{"b": "x"}},
+			want:   []Difference{{Type: Changed, Path: "a", Source: "x", Target: map[string]any{"b": "x"}}},
+		},
+		{
+			name: "containers are matched by name regardless of their order",
+			source: map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:1"},
+				map[string]any{"name": "sidecar", "image": "proxy:1"},
+			}},
+			target: map[string]any{"containers": []any{
+				map[string]any{"name": "sidecar", "image": "proxy:1"},
+				map[string]any{"name": "app", "image": "app:2"},
+			}},
+			want: []Difference{{Type: Changed, Path: "containers[name=app].image", Source: "app:1", Target: "app:2"}},
+		},
+		{
+			name:   "elements only in one list are removed or added by key",
+			source: map[string]any{"env": []any{map[string]any{"name": "A", "value": "1"}}},
+			target: map[string]any{"env": []any{map[string]any{"name": "B", "value": "2"}}},
+			want: []Difference{
+				{Type: Removed, Path: "env[name=A]", Source: map[string]any{"name": "A", "value": "1"}},
+				{Type: Added, Path: "env[name=B]", Target: map[string]any{"name": "B", "value": "2"}},
+			},
+		},
+		{
+			name:   "ports are matched by port and protocol, which defaults to TCP",
+			source: map[string]any{"ports": []any{map[string]any{"containerPort": int64(80), "name": "http"}}},
+			target: map[string]any{"ports": []any{map[string]any{"containerPort": int64(80), "protocol": "TCP", "name": "web"}}},
+			want: []Difference{
+				{Type: Changed, Path: "ports[containerPort=80,protocol=TCP].name", Source: "http", Target: "web"},
+				{Type: Added, Path: "ports[containerPort=80,protocol=TCP].protocol", Target: "TCP"},
+			},
+		},
+		{
+			name:   "unknown lists whose elements have unique names are matched by name",
+			source: map[string]any{"rules": []any{map[string]any{"name": "a", "v": "1"}, map[string]any{"name": "b", "v": "1"}}},
+			target: map[string]any{"rules": []any{map[string]any{"name": "b", "v": "2"}, map[string]any{"name": "a", "v": "1"}}},
+			want:   []Difference{{Type: Changed, Path: "rules[name=b].v", Source: "1", Target: "2"}},
+		},
+		{
+			name:   "lists with duplicated names are matched by index",
+			source: map[string]any{"containers": []any{map[string]any{"name": "a", "image": "x"}, map[string]any{"name": "a", "image": "y"}}},
+			target: map[string]any{"containers": []any{map[string]any{"name": "a", "image": "y"}}},
+			want: []Difference{
+				{Type: Changed, Path: "containers[0].image", Source: "x", Target: "y"},
+				{Type: Removed, Path: "containers[1]", Source: map[string]any{"name": "a", "image": "y"}},
+			},
+		},
+		{
+			name:   "scalar lists are matched by index",
+			source: map[string]any{"args": []any{"a", "b"}},
+			target: map[string]any{"args": []any{"a", "c", "d"}},
+			want: []Difference{
+				{Type: Changed, Path: "args[1]", Source: "b", Target: "c"},
+				{Type: Added, Path: "args[2]", Target: "d"},
+			},
+		},
+		{
+			name:   "typed values are compared as unstructured values",
+			source: struct{ Replicas int }{Replicas: 2},
+			target: map[string]any{"Replicas": int64(3)},
+			want:   []Difference{{Type: Changed, Path: "Replicas", Source: json.Number("2"), Target: int64(3)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.source, tt.target)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
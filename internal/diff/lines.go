@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineOp is the operation applied to a line to transform the source text into the target text
+type LineOp byte
+
+const (
+	// Equal means the line exists in both texts
+	Equal LineOp = ' '
+	// Delete means the line only exists in the source text
+	Delete LineOp = '-'
+	// Insert means the line only exists in the target text
+	Insert LineOp = '+'
+)
+
+// Line is a line of a line diff
+type Line struct {
+	Op   LineOp
+	Text string
+	// SourceLine and TargetLine are the 1-based line numbers in each text, 0 when the line does not exist in it
+	SourceLine int
+	TargetLine int
+}
+
+// SplitLines splits a text into lines, a trailing newline does not add an empty line
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// maxSnakeCost is the maximum number of changes searched for the middle snake of a block of lines. Blocks whose
+// middle snake is not found within it, i.e. texts that are mostly different, are replaced as a whole, so the
+// time is bounded for large texts
+const maxSnakeCost = 1000
+
+// Lines returns the line diff between source and target computed with the linear space variant of the Myers
+// algorithm, which finds the middle snake of the edit script and diffs the texts before and after it, so the
+// memory used is proportional to the number of lines and not to the number of changes. The diff is minimal
+// unless a block of lines has more than 2*maxSnakeCost changes
+func Lines(source, target []string) []Line {
+	max := len(source) + len(target) + 1
+	d := &lineDiff{
+		source:   source,
+		target:   target,
+		offset:   max + 1,
+		forward:  make([]int, 2*max+3),
+		backward: make([]int, 2*max+3),
+	}
+	d.compare(0, len(source), 0, len(target))
+	return d.lines
+}
+
+// lineDiff computes the line diff of source and target. forward and backward are the furthest reaching paths
+// of each diagonal, shared by every middle snake search
+type lineDiff struct {
+	source, target    []string
+	offset            int
+	forward, backward []int
+	lines             []Line
+}
+
+// compare appends the line diff of source[x0:x1] and target[y0:y1]
+func (d *lineDiff) compare(x0, x1, y0, y1 int) {
+	for x0 < x1 && y0 < y1 && d.source[x0] == d.target[y0] {
+		d.equal(x0, y0)
+		x0, y0 = x0+1, y0+1
+	}
+	suffix := 0
+	for x1 > x0 && y1 > y0 && d.source[x1-1] == d.target[y1-1] {
+		x1, y1, suffix = x1-1, y1-1, suffix+1
+	}
+	// without common prefix and suffix there are at least two changes, so the blocks before and after the middle
+	// snake have fewer changes
+	if x, y, u, v, ok := d.middleSnake(x0, x1, y0, y1); ok {
+		d.compare(x0, x, y0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.equal(x, y)
+		}
+		d.compare(u, x1, v, y1)
+	} else {
+		d.replace(x0, x1, y0, y1)
+	}
+	for i := 0; i < suffix; i++ {
+		d.equal(x1+i, y1+i)
+	}
+}
+
+// replace appends source[x0:x1] as deleted and target[y0:y1] as inserted
+func (d *lineDiff) replace(x0, x1, y0, y1 int) {
+	for x := x0; x < x1; x++ {
+		d.lines = append(d.lines, Line{Op: Delete, Text: d.source[x], SourceLine: x + 1})
+	}
+	for y := y0; y < y1; y++ {
+		d.lines = append(d.lines, Line{Op: Insert, Text: d.target[y], TargetLine: y + 1})
+	}
+}
+
+func (d *lineDiff) equal(x, y int) {
+	d.lines = append(d.lines, Line{Op: Equal, Text: d.source[x], SourceLine: x + 1, TargetLine: y + 1})
+}
+
+// middleSnake returns the start (x, y) and the end (u, v) of the snake in the middle of the shortest edit script
+// of source[x0:x1] and target[y0:y1], found by searching forward from the start and backward from the end until
+// the paths overlap. The backward paths are the forward paths of the reversed texts. Returns false if either text
+// is empty or the snake is not found within maxSnakeCost changes
+func (d *lineDiff) middleSnake(x0, x1, y0, y1 int) (x, y, u, v int, ok bool) {
+	n, m := x1-x0, y1-y0
+	if n == 0 || m == 0 {
+		return 0, 0, 0, 0, false
+	}
+	delta := n - m
+	odd := delta%2 != 0
+	forward, backward, offset := d.forward, d.backward, d.offset
+	forward[offset+1], backward[offset+1] = 0, 0
+	for D := 0; D <= (n+m+1)/2 && D <= maxSnakeCost; D++ {
+		for k := -D; k <= D; k += 2 {
+			var start int
+			if k == -D || (k != D && forward[offset+k-1] < forward[offset+k+1]) {
+				start = forward[offset+k+1]
+			} else {
+				start = forward[offset+k-1] + 1
+			}
+			end := start
+			for end < n && end-k < m && d.source[x0+end] == d.target[y0+end-k] {
+				end++
+			}
+			forward[offset+k] = end
+			if reverse := delta - k; odd && reverse >= -(D-1) && reverse <= D-1 && end+backward[offset+reverse] >= n {
+				return x0 + start, y0 + start - k, x0 + end, y0 + end - k, true
+			}
+		}
+		for k := -D; k <= D; k += 2 {
+			var start int
+			if k == -D || (k != D && backward[offset+k-1] < backward[offset+k+1]) {
+				start = backward[offset+k+1]
+			} else {
+				start = backward[offset+k-1] + 1
+			}
+			end := start
+			for end < n && end-k < m && d.source[x1-1-end] == d.target[y1-1-end+k] {
+				end++
+			}
+			backward[offset+k] = end
+			if reverse := delta - k; !odd && reverse >= -D && reverse <= D && end+forward[offset+reverse] >= n {
+				return x1 - end, y1 - end + k, x1 - start, y1 - start + k, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// Unified returns the line diff between source and target in the unified format, with `context` unchanged
+// lines around each change. Returns an empty string if the texts have the same lines
+func Unified(source, target string, context int) string {
+	var out strings.Builder
+	for _, hunk := range Hunks(Lines(SplitLines(source), SplitLines(target)), context) {
+		writeHunk(&out, hunk)
+	}
+	return out.String()
+}
+
+// Hunks groups the changed lines of a line diff with `context` unchanged lines around each change. Changes
+// separated by up to 2*context unchanged lines are in the same hunk
+func Hunks(lines []Line, context int) [][]Line {
+	var hunks [][]Line
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		// a hunk starts `context` lines before the change and ends when there are more than
+		// 2*context unchanged lines until the next change
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*context {
+				break
+			}
+		}
+		hunkEnd := end + context
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+		hunks = append(hunks, lines[start:hunkEnd])
+		i = hunkEnd
+	}
+	return hunks
+}
+
+func writeHunk(out *strings.Builder, lines []Line) {
+	fmt.Fprintln(out, HunkHeader(lines))
+	for _, line := range lines {
+		fmt.Fprintf(out, "%c%s\n", line.Op, line.Text)
+	}
+}
+
+// HunkHeader returns the header of a hunk with the first line and the number of lines of each text, e.g.
+// @@ -3,7 +3,6 @@
+func HunkHeader(lines []Line) string {
+	sourceStart, sourceCount, targetStart, targetCount := 0, 0, 0, 0
+	for _, line := range lines {
+		if line.Op != Insert {
+			if sourceStart == 0 {
+				sourceStart = line.SourceLine
+			}
+			sourceCount++
+		}
+		if line.Op != Delete {
+			if targetStart == 0 {
+				targetStart = line.TargetLine
+			}
+			targetCount++
+		}
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", sourceStart, sourceCount, targetStart, targetCount)
+}
@@ -0,0 +1,218 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		target string
+		want   []Line
+	}{
+		{name: "empty texts"},
+		{
+			name:   "equal texts",
+			source: "a\nb\n",
+			target: "a\nb\n",
+			want: []Line{
+				{Op: Equal, Text: "a", SourceLine: 1, TargetLine: 1},
+				{Op: Equal, Text: "b", SourceLine: 2, TargetLine: 2},
+			},
+		},
+		{
+			name:   "inserted text",
+			target: "a\n",
+			want:   []Line{{Op: Insert, Text: "a", TargetLine: 1}},
+		},
+		{
+			name:   "deleted text",
+			source: "a\n",
+			want:   []Line{{Op: Delete, Text: "a", SourceLine: 1}},
+		},
+		{
+			name:   "changed line",
+			source: "a\nb\nc\n",
+			target: "a\nx\nc\n",
+			want: []Line{
+				{Op: Equal, Text: "a", SourceLine: 1, TargetLine: 1},
+				{Op: Delete, Text: "b", SourceLine: 2},
+				{Op: Insert, Text: "x", TargetLine: 2},
+				{Op: Equal, Text: "c", SourceLine: 3, TargetLine: 3},
+			},
+		},
+		{
+			name:   "moved line",
+			source: "a\nb\nc\n",
+			target: "b\nc\na\n",
+			want: []Line{
+				{Op: Delete, Text: "a", SourceLine: 1},
+				{Op: Equal, Text: "b", SourceLine: 2, TargetLine: 1},
+				{Op: Equal, Text: "c", SourceLine: 3, TargetLine: 2},
+				{Op: Insert, Text: "a", TargetLine: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(SplitLines(tt.source), SplitLines(tt.target))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestLinesMinimal checks that the line diff of random texts rebuilds both texts, has valid line numbers and
+// has the minimal number of changes
+func TestLinesMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomText := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		source, target := randomText(), randomText()
+		lines := Lines(source, target)
+		var gotSource, gotTarget []string
+		changes := 0
+		for _, line := range lines {
+			if line.Op != Insert {
+				gotSource = append(gotSource, line.Text)
+				if line.SourceLine != len(gotSource) {
+					t.Fatalf("%v to %v: expected source line %d, got %d", source, target, len(gotSource), line.SourceLine)
+				}
+			}
+			if line.Op != Delete {
+				gotTarget = append(gotTarget, line.Text)
+				if line.TargetLine != len(gotTarget) {
+					t.Fatalf("%v to %v: expected target line %d, got %d", source, target, len(gotTarget), line.TargetLine)
+				}
+			}
+			if line.Op != Equal {
+				changes++
+			}
+		}
+		if strings.Join(gotSource, "\n") != strings.Join(source, "\n") || strings.Join(gotTarget, "\n") != strings.Join(target, "\n") {
+			t.Fatalf("%v to %v: the diff does not rebuild the texts: %+v", source, target, lines)
+		}
+		if want := len(source) + len(target) - 2*lcs(source, target); changes != want {
+			t.Fatalf("%v to %v: expected %d changes, got %d", source, target, want, changes)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b
+func lcs(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestLinesLargeTexts(t *testing.T) {
+	source, target := make([]string, 50000), make([]string, 50000)
+	for i := range source {
+		source[i] = "source"
+		target[i] = "target"
+	}
+	lines := Lines(source, target)
+	if len(lines) != len(source)+len(target) {
+		t.Errorf("expected every line to change, got %d lines", len(lines))
+	}
+}
+
+func TestHunks(t *testing.T) {
+	source := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	tests := []struct {
+		name    string
+		target  string
+		context int
+		want    []string
+	}{
+		{name: "no changes", target: source, context: 3},
+		{
+			name:    "a change with context",
+			target:  "1\n2\n3\n4\n5\nx\n7\n8\n9\n10\n11\n12\n",
+			context: 2,
+			want:    []string{"@@ -4,5 +4,5 @@"},
+		},
+		{
+			name:    "context at the start and end of the texts",
+			target:  "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			context: 3,
+			want:    []string{"@@ -1,4 +1,4 @@", "@@ -9,4 +9,3 @@"},
+		},
+		{
+			name:    "close changes are in the same hunk",
+			target:  "1\n2\nx\n4\n5\n6\ny\n8\n9\n10\n11\n12\n",
+			context: 2,
+			want:    []string{"@@ -1,9 +1,9 @@"},
+		},
+		{
+			name:    "changes without context",
+			target:  "1\n2\nx\n4\n5\n6\ny\n8\n9\n10\n11\n12\n",
+			context: 0,
+			want:    []string{"@@ -3,1 +3,1 @@", "@@ -7,1 +7,1 @@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, hunk := range Hunks(Lines(SplitLines(source), SplitLines(tt.target)), tt.context) {
+				got = append(got, HunkHeader(hunk))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		target string
+		want   string
+	}{
+		{name: "equal texts", source: "a: 1\n", target: "a: 1\n"},
+		{
+			name:   "changed value",
+			source: "a: 1\nb: 2\nc: 3\n",
+			target: "a: 1\nb: 4\nc: 3\n",
+			want:   "@@ -1,3 +1,3 @@\n a: 1\n-b: 2\n+b: 4\n c: 3\n",
+		},
+		{
+			name:   "added key",
+			source: "a: 1\n",
+			target: "a: 1\nb: 2\n",
+			want:   "@@ -1,1 +1,2 @@\n a: 1\n+b: 2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(tt.source, tt.target, 3); got != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
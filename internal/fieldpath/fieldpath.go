@@ -0,0 +1,151 @@
+// Package fieldpath implements the evaluation of the paths used to select the fields to compare
+package fieldpath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Root is the path of the whole object
+const Root = "$"
+
+// Path is a parsed path. Paths use the kubectl JSONPath dialect over the JSON field names of
+// the object (e.g. `spec.template.spec.containers[?(@.name=="app")].image`). Paths using Go
+// struct field names (e.g. `Spec.Template.Spec.Containers[*].Image`) are still accepted for
+// typed objects but are deprecated
+type Path struct {
+	raw      string
+	template string
+	legacy   bool
+	root     bool
+}
+
+// Parse parses a path. The leading `$`, `.` and the surrounding braces are optional, so
+// `spec.replicas`, `.spec.replicas`, `$.spec.replicas` and `{.spec.replicas}` are equivalent.
+// `$` and `.` select the whole object
+func Parse(path string) (*Path, error) {
+	raw := strings.TrimSpace(path)
+	if raw == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+	if IsRoot(raw) {
+		return &Path{raw: raw, root: true}, nil
+	}
+	if isLegacy(raw) {
+		return &Path{raw: raw, legacy: true}, nil
+	}
+
+	expr := raw
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSuffix(strings.TrimPrefix(expr, "{"), "}")
+	}
+	expr = strings.TrimPrefix(expr, "$")
+	if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") {
+		expr = "." + expr
+	}
+	p := &Path{raw: raw, template: "{" + expr + "}"}
+	if _, err := p.newJSONPath(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// IsRoot reports if a path selects the whole object, i.e. it is $ or .
+func IsRoot(path string) bool {
+	path = strings.TrimSpace(path)
+	return path == Root || path == "."
+}
+
+// String returns the path as provided by the user
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Legacy reports if the path uses Go struct field names
+func (p *Path) Legacy() bool {
+	return p.legacy
+}
+
+// Values returns every value matched by the path in obj. Missing fields are not matched, so an
+// object without the field returns no values
+func (p *Path) Values(obj any) ([]any, error) {
+	if p.legacy {
+		val, err := getFieldValue(obj, p.raw)
+		if err != nil {
+			return nil, err
+		}
+		if vals, ok := val.([]interface{}); ok {
+			return vals, nil
+		}
+		return []any{val}, nil
+	}
+
+	content, err := ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	if p.root {
+		return []any{content}, nil
+	}
+	// a JSONPath keeps state while walking the object so it can not be shared between goroutines
+	jp, err := p.newJSONPath()
+	if err != nil {
+		return nil, err
+	}
+	results, err := jp.FindResults(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate path %q: %v", p.raw, err)
+	}
+	values := []any{}
+	for _, result := range results {
+		for _, value := range result {
+			values = append(values, value.Interface())
+		}
+	}
+	return values, nil
+}
+
+func (p *Path) newJSONPath() (*jsonpath.JSONPath, error) {
+	jp := jsonpath.New("path").AllowMissingKeys(true)
+	if err := jp.Parse(p.template); err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", p.raw, err)
+	}
+	return jp, nil
+}
+
+// ToUnstructured returns the unstructured content of a typed object. Unstructured content is returned as is
+func ToUnstructured(obj any) (map[string]interface{}, error) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		return o, nil
+	case runtime.Unstructured:
+		return o.UnstructuredContent(), nil
+	case nil:
+		return nil, fmt.Errorf("failed to convert nil object to unstructured")
+	}
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr {
+		// the converter only accepts pointers
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		val = ptr
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(val.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %T to unstructured: %v", obj, err)
+	}
+	return content, nil
+}
+
+// isLegacy checks if the path uses Go struct field names, i.e. starts with an upper case letter
+func isLegacy(path string) bool {
+	for _, r := range path {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
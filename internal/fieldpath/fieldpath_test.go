@@ -0,0 +1,121 @@
+package fieldpath
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path    string
+		legacy  bool
+		root    bool
+		wantErr bool
+	}{
+		{path: "spec.replicas"},
+		{path: ".spec.replicas"},
+		{path: "$.spec.replicas"},
+		{path: "{.spec.replicas}"},
+		{path: `spec.template.spec.containers[?(@.name=="app")].image`},
+		{path: "$", root: true},
+		{path: ".", root: true},
+		{path: "Spec.Template.Spec.Containers[*].Image", legacy: true},
+		{path: "Data", legacy: true},
+		{path: "", wantErr: true},
+		{path: "spec.containers[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := Parse(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Legacy() != tt.legacy {
+				t.Errorf("expected legacy %v, got %v", tt.legacy, p.Legacy())
+			}
+			if p.root != tt.root {
+				t.Errorf("expected root %v, got %v", tt.root, p.root)
+			}
+			if p.String() != tt.path {
+				t.Errorf("expected the path as provided %q, got %q", tt.path, p.String())
+			}
+		})
+	}
+}
+
+func TestIsRoot(t *testing.T) {
+	tests := map[string]bool{
+		"$":             true,
+		".":             true,
+		" . ":           true,
+		"$.spec":        false,
+		".spec":         false,
+		"spec.replicas": false,
+		"":              false,
+	}
+	for path, want := range tests {
+		if got := IsRoot(path); got != want {
+			t.Errorf("%q: expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	replicas := int32(2)
+	cpu, proxyCPU := resource.MustParse("500m"), resource.MustParse("100m")
+	deploy := appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Image: "app:1", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: cpu}}},
+				{Name: "sidecar", Image: "proxy:1", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: proxyCPU}}},
+			}}},
+		},
+	}
+	unstructured := map[string]any{
+		"spec": map[string]any{"replicas": int64(2)},
+		"data": map[string]any{"key": "value"},
+	}
+	tests := []struct {
+		name string
+		path string
+		obj  any
+		want []any
+	}{
+		{name: "typed field", path: "spec.replicas", obj: deploy, want: []any{int64(2)}},
+		{name: "typed pointer", path: "spec.replicas", obj: &deploy, want: []any{int64(2)}},
+		{name: "every element of a list", path: "spec.template.spec.containers[*].image", obj: deploy, want: []any{"app:1", "proxy:1"}},
+		{name: "filtered element", path: `spec.template.spec.containers[?(@.name=="sidecar")].image`, obj: deploy, want: []any{"proxy:1"}},
+		{name: "unstructured field", path: "$.spec.replicas", obj: unstructured, want: []any{int64(2)}},
+		{name: "missing field", path: "spec.paused", obj: unstructured, want: []any{}},
+		{name: "whole object", path: "$", obj: unstructured, want: []any{unstructured}},
+		{name: "legacy typed path", path: "Spec.Template.Spec.Containers[*].Image", obj: deploy, want: []any{"app:1", "proxy:1"}},
+		{name: "map key", path: "data.key", obj: unstructured, want: []any{"value"}},
+		{name: "legacy typed map key", path: "Spec.Template.Spec.Containers[*].Resources.Limits.cpu", obj: deploy, want: []any{cpu, proxyCPU}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Values(tt.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package fieldpath
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// getFieldValue returns the value found in `path`. Path parts are struct field names for typed objects
+// and keys for unstructured objects
+func getFieldValue(data interface{}, path string) (interface{}, error) {
+	parts := strings.Split(path, ".")
+	val := reflect.ValueOf(data)
+
+	for i, part := range parts {
+		// Dereference pointer if necessary
+		val = indirect(val)
+
+		if strings.HasSuffix(part, "[*]") {
+			// Handle the slice iteration
+			fieldName := strings.TrimSuffix(part, "[*]")
+			val = fieldByName(val, fieldName)
+			if val.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("field '%s' is not a slice", fieldName)
+			}
+
+			// Handle the remaining path for each slice element
+			remainingPath := strings.Join(parts[i+1:], ".")
+			results := []interface{}{}
+			for j := 0; j < val.Len(); j++ {
+				item := val.Index(j).Interface()
+				if remainingPath != "" {
+					// Recursively process the remaining path
+					result, err := getFieldValue(item, remainingPath)
+					if err != nil {
+						return nil, err
+					}
+					results = append(results, result)
+				} else {
+					results = append(results, item)
+				}
+			}
+			return results, nil
+		}
+		// Normal struct field or map key access
+		val = fieldByName(val, part)
+		if !val.IsValid() {
+			return nil, fmt.Errorf("field '%s' not found", part)
+		}
+
+	}
+
+	return val.Interface(), nil
+}
+
+// fieldByName returns the struct field or the map entry with the given name. Map keys can be named
+// string types, e.g. the resource names of a corev1.ResourceList
+func fieldByName(val reflect.Value, name string) reflect.Value {
+	switch val.Kind() {
+	case reflect.Struct:
+		return indirect(val.FieldByName(name))
+	case reflect.Map:
+		key := reflect.ValueOf(name)
+		if !key.Type().ConvertibleTo(val.Type().Key()) {
+			return reflect.Value{}
+		}
+		return indirect(val.MapIndex(key.Convert(val.Type().Key())))
+	}
+	return reflect.Value{}
+}
+
+// indirect dereferences pointers and interfaces
+func indirect(val reflect.Value) reflect.Value {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return val
+		}
+		val = val.Elem()
+	}
+	return val
+}
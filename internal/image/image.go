@@ -0,0 +1,146 @@
+// Package image implements the parsing of container image references
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// DefaultRegistry is the registry of the images without registry, e.g. nginx
+	DefaultRegistry = "docker.io"
+	// DefaultTag is the tag of the images without tag and digest
+	DefaultTag = "latest"
+	// officialRepository is the namespace of the official images of the default registry
+	officialRepository = "library/"
+)
+
+// Reference is a container image reference, e.g. docker.io/library/nginx:1.27@sha256:...
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parse parses an image reference in the format [registry/]repository[:tag][@digest]. References are
+// normalized the way the container runtime does: nginx is docker.io/library/nginx:latest
+func Parse(s string) (Reference, error) {
+	var ref Reference
+	name := strings.TrimSpace(s)
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !strings.Contains(ref.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid image %q, digest must be in the format <algorithm>:<hex>", s)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if ref.Tag == "" {
+			return Reference{}, fmt.Errorf("invalid image %q, empty tag", s)
+		}
+	}
+	if name == "" || strings.ContainsAny(name, " \t\n@") {
+		return Reference{}, fmt.Errorf("invalid image %q", s)
+	}
+	ref.Registry, ref.Repository = splitRegistry(name)
+	if ref.Repository == "" {
+		return Reference{}, fmt.Errorf("invalid image %q, missing repository", s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DefaultTag
+	}
+	return ref, nil
+}
+
+// splitRegistry splits a name into the registry and the repository. The first component is the registry
+// when it is a hostname, i.e. it has a dot or a port, or is localhost
+func splitRegistry(name string) (string, string) {
+	registry, repository, ok := strings.Cut(name, "/")
+	if !ok || !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		registry, repository = DefaultRegistry, name
+	}
+	if registry == "index.docker.io" {
+		registry = DefaultRegistry
+	}
+	if registry == DefaultRegistry && !strings.Contains(repository, "/") {
+		repository = officialRepository + repository
+	}
+	return registry, repository
+}
+
+// Name returns the registry and repository, e.g. docker.io/library/nginx
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// ShortRepository returns the repository without the namespace of the official images, e.g. nginx for
+// docker.io/library/nginx, so it matches the repository of the same image in a mirror, e.g. mirror.corp/nginx
+func (r Reference) ShortRepository() string {
+	return strings.TrimPrefix(r.Repository, officialRepository)
+}
+
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithAliases returns the reference with the longest alias prefix of its name replaced, e.g. the alias
+// mirror.corp/=docker.io/ replaces mirror.corp/nginx with docker.io/library/nginx
+func (r Reference) WithAliases(aliases map[string]string) Reference {
+	name := r.Name() + "/"
+	longest := ""
+	for alias := range aliases {
+		prefix := withSlash(alias)
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(longest) {
+			longest = alias
+		}
+	}
+	if longest == "" {
+		return r
+	}
+	replaced := withSlash(aliases[longest]) + strings.TrimPrefix(name, withSlash(longest))
+	registry, repository := splitRegistry(strings.Trim(replaced, "/"))
+	if repository == "" {
+		return r
+	}
+	r.Registry, r.Repository = registry, repository
+	return r
+}
+
+// ParseAlias parses a registry alias in the format <alias>=<registry>, e.g. mirror.corp/=docker.io/
+func ParseAlias(s string) (string, string, error) {
+	alias, registry, ok := strings.Cut(s, "=")
+	alias, registry = strings.TrimSpace(alias), strings.TrimSpace(registry)
+	if !ok || alias == "" || registry == "" {
+		return "", "", fmt.Errorf("invalid registry alias %q, expected <alias>=<registry>, e.g. mirror.corp/=docker.io/", s)
+	}
+	return alias, registry, nil
+}
+
+// Version returns the version of the tag, e.g. v1.2.3 or 1.27-alpine. Returns false if the tag is not a version
+func (r Reference) Version() (*version.Version, bool) {
+	if v, err := version.ParseSemantic(strings.TrimPrefix(r.Tag, "v")); err == nil {
+		return v, true
+	}
+	if v, err := version.ParseGeneric(r.Tag); err == nil {
+		return v, true
+	}
+	return nil, false
+}
+
+// withSlash returns s with a trailing slash so prefixes match whole components
+func withSlash(s string) string {
+	if strings.HasSuffix(s, "/") {
+		return s
+	}
+	return s + "/"
+}
@@ -0,0 +1,128 @@
+package image
+
+import "testing"
+
+const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		image   string
+		want    Reference
+		wantErr bool
+	}{
+		{image: "nginx", want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{image: "nginx:1.27", want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"}},
+		{image: "library/nginx", want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{image: "docker.io/nginx", want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{image: "index.docker.io/library/nginx:1.27", want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"}},
+		{image: "bitnami/redis:7", want: Reference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7"}},
+		{image: "mirror.corp/nginx:1", want: Reference{Registry: "mirror.corp", Repository: "nginx", Tag: "1"}},
+		{image: "localhost:5000/x", want: Reference{Registry: "localhost:5000", Repository: "x", Tag: "latest"}},
+		{image: "localhost/x:1", want: Reference{Registry: "localhost", Repository: "x", Tag: "1"}},
+		{image: "ghcr.io/org/team/app:v1.2.3", want: Reference{Registry: "ghcr.io", Repository: "org/team/app", Tag: "v1.2.3"}},
+		{image: "nginx@" + digest, want: Reference{Registry: "docker.io", Repository: "library/nginx", Digest: digest}},
+		{image: "nginx:1.27@" + digest, want: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27", Digest: digest}},
+		{image: "localhost:5000/x@" + digest, want: Reference{Registry: "localhost:5000", Repository: "x", Digest: digest}},
+		{image: "", wantErr: true},
+		{image: "nginx:", wantErr: true},
+		{image: "nginx@1234", wantErr: true},
+		{image: "mirror.corp/", wantErr: true},
+		{image: "my image", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := Parse(tt.image)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		aliases map[string]string
+		want    string
+	}{
+		{
+			name:    "mirror of the default registry",
+			image:   "mirror.corp/nginx:1",
+			aliases: map[string]string{"mirror.corp/": "docker.io/"},
+			want:    "docker.io/library/nginx:1",
+		},
+		{
+			name:    "mirror of the library namespace",
+			image:   "mirror.corp/library/nginx:1",
+			aliases: map[string]string{"mirror.corp": "docker.io"},
+			want:    "docker.io/library/nginx:1",
+		},
+		{
+			name:    "local registry",
+			image:   "localhost:5000/x@" + digest,
+			aliases: map[string]string{"localhost:5000/": "ghcr.io/org/"},
+			want:    "ghcr.io/org/x@" + digest,
+		},
+		{
+			name:    "the longest alias is used",
+			image:   "mirror.corp/team/app:1",
+			aliases: map[string]string{"mirror.corp/": "docker.io/", "mirror.corp/team/": "ghcr.io/team/"},
+			want:    "ghcr.io/team/app:1",
+		},
+		{
+			name:    "aliases match whole components",
+			image:   "mirror.corporate/nginx:1",
+			aliases: map[string]string{"mirror.corp": "docker.io"},
+			want:    "mirror.corporate/nginx:1",
+		},
+		{
+			name:    "aliases match the normalized name",
+			image:   "nginx:1",
+			aliases: map[string]string{"docker.io/library/": "mirror.corp/"},
+			want:    "mirror.corp/nginx:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := Parse(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ref.WithAliases(tt.aliases).String(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShortRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                 "nginx",
+		"library/nginx":         "nginx",
+		"mirror.corp/nginx":     "nginx",
+		"bitnami/redis":         "bitnami/redis",
+		"localhost:5000/x":      "x",
+		"ghcr.io/library/nginx": "nginx",
+	}
+	for image, want := range tests {
+		t.Run(image, func(t *testing.T) {
+			ref, err := Parse(image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ref.ShortRepository(); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// APIResource identifies a resource served by the API server
+type APIResource struct {
+	GroupVersionResource schema.GroupVersionResource
+	Kind                 string
+	Namespaced           bool
+}
+
+// preferredGroups are preferred, in order, when a name matches resources of several groups, e.g. the
+// events of the core group over the events of events.k8s.io
+var preferredGroups = []string{"", "apps"}
+
+// ResolveResource finds the resource served by the API server that matches `name`. `name` can be
+// a kind (Deployment), a plural (deployments), a singular (deployment), a short name (deploy),
+// a group qualified name (deployments.apps) or a group/version/resource (apps/v1/deployments).
+// When `name` matches resources of several groups, the group of `name` and then the core and apps
+// groups are preferred. Otherwise the name is ambiguous and an error lists the matching resources
+func ResolveResource(client discovery.DiscoveryInterface, name string) (*APIResource, error) {
+	group, version, resource, err := ParseResourceName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := client.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %v", err)
+	}
+	if version != "" {
+		// preferred resources only list the preferred version of each group
+		list, err := client.ServerResourcesForGroupVersion(schema.GroupVersion{Group: group, Version: version}.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover resources for %s: %v", name, err)
+		}
+		lists = []*v1.APIResourceList{list}
+	}
+
+	var candidates []*APIResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		if group != "" && gv.Group != group && !strings.HasPrefix(gv.Group, group+".") {
+			continue
+		}
+		for _, r := range list.APIResources {
+			// skip subresources such as deployments/status
+			if strings.Contains(r.Name, "/") || !resourceMatches(r, resource) || !isListable(r) {
+				continue
+			}
+			candidates = append(candidates, &APIResource{
+				GroupVersionResource: gv.WithResource(r.Name),
+				Kind:                 r.Kind,
+				Namespaced:           r.Namespaced,
+			})
+			// a group version has a single match, as names are unique in a group
+			break
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("resource %s not found in the server", name)
+	case 1:
+		return candidates[0], nil
+	}
+	for _, preferred := range append([]string{group}, preferredGroups...) {
+		for _, candidate := range candidates {
+			if candidate.GroupVersionResource.Group == preferred {
+				return candidate, nil
+			}
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		names = append(names, candidate.GroupVersionResource.Resource+"."+candidate.GroupVersionResource.Group)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("resource %s is ambiguous, use one of %s", name, strings.Join(names, ", "))
+}
+
+// ParseResourceName splits a resource name in the formats accepted by ResolveResource into its group, version
+// and resource. The version is only set for the group/version/resource format, in which case an empty group
+// means the core group
+func ParseResourceName(name string) (group, version, resource string, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", "", "", fmt.Errorf("empty resource name")
+	}
+	switch parts := strings.Split(name, "/"); len(parts) {
+	case 1:
+		resource = name
+		if i := strings.Index(name, "."); i > 0 {
+			resource, group = name[:i], name[i+1:]
+		}
+	case 2:
+		// core group resources, e.g. v1/services
+		version, resource = parts[0], parts[1]
+	case 3:
+		group, version, resource = parts[0], parts[1], parts[2]
+	default:
+		return "", "", "", fmt.Errorf("invalid resource %q, expected <resource>, <resource>.<group> or <group>/<version>/<resource>", name)
+	}
+	return group, version, resource, nil
+}
+
+// resourceMatches checks if `name` is the plural, singular, kind or short name of the resource
+func resourceMatches(r v1.APIResource, name string) bool {
+	if r.Name == name || r.SingularName == name || strings.ToLower(r.Kind) == name {
+		return true
+	}
+	for _, shortName := range r.ShortNames {
+		if shortName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isListable checks if the resource supports the list verb
+func isListable(r v1.APIResource) bool {
+	for _, verb := range r.Verbs {
+		if verb == "list" {
+			return true
+		}
+	}
+	return false
+}
+
+type ListResourcesOpts struct {
+	Namespace string
+	Labels    []string
+	Timeout   time.Duration
+}
+
+func ListResources(ctx context.Context, d dynamic.Interface, resource *APIResource, opts ListResourcesOpts) (*unstructured.UnstructuredList, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var client dynamic.ResourceInterface = d.Resource(resource.GroupVersionResource)
+	if resource.Namespaced {
+		client = d.Resource(resource.GroupVersionResource).Namespace(opts.Namespace)
+	}
+	list, err := client.List(ctx, v1.ListOptions{
+		LabelSelector: joinLabels(opts.Labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", resource.GroupVersionResource.Resource, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,83 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// preferredDiscovery is a fake discovery client that serves its resources as the preferred resources
+type preferredDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d preferredDiscovery) ServerPreferredResources() ([]*v1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+func TestResolveResource(t *testing.T) {
+	verbs := v1.Verbs{"get", "list"}
+	client := preferredDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*v1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []v1.APIResource{
+			{Name: "events", SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}, Namespaced: true, Verbs: verbs},
+			{Name: "services", SingularName: "service", Kind: "Service", ShortNames: []string{"svc"}, Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "events.k8s.io/v1", APIResources: []v1.APIResource{
+			{Name: "events", SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}, Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []v1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Kind: "Deployment", ShortNames: []string{"deploy"}, Namespaced: true, Verbs: verbs},
+			{Name: "deployments/status", Kind: "Deployment", Namespaced: true, Verbs: v1.Verbs{"get"}},
+		}},
+		{GroupVersion: "argoproj.io/v1alpha1", APIResources: []v1.APIResource{
+			{Name: "rollouts", SingularName: "rollout", Kind: "Rollout", ShortNames: []string{"ro"}, Namespaced: true, Verbs: verbs},
+			{Name: "applications", SingularName: "application", Kind: "Application", ShortNames: []string{"app"}, Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "app.k8s.io/v1beta1", APIResources: []v1.APIResource{
+			{Name: "applications", SingularName: "application", Kind: "Application", ShortNames: []string{"app"}, Namespaced: true, Verbs: verbs},
+		}},
+		{GroupVersion: "rbac.authorization.k8s.io/v1", APIResources: []v1.APIResource{
+			{Name: "clusterroles", SingularName: "clusterrole", Kind: "ClusterRole", Verbs: verbs},
+		}},
+	}}}}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{name: "Deployment", want: "apps/v1, Resource=deployments"},
+		{name: "deploy", want: "apps/v1, Resource=deployments"},
+		{name: "deployments.apps", want: "apps/v1, Resource=deployments"},
+		{name: "apps/v1/deployments", want: "apps/v1, Resource=deployments"},
+		{name: "clusterroles.rbac", want: "rbac.authorization.k8s.io/v1, Resource=clusterroles"},
+		{name: "ro", want: "argoproj.io/v1alpha1, Resource=rollouts"},
+		// the core group is preferred over events.k8s.io
+		{name: "ev", want: "/v1, Resource=events"},
+		{name: "events.events.k8s.io", want: "events.k8s.io/v1, Resource=events"},
+		{name: "applications.argoproj.io", want: "argoproj.io/v1alpha1, Resource=applications"},
+		{name: "app", wantErr: "resource app is ambiguous, use one of applications.app.k8s.io, applications.argoproj.io"},
+		{name: "Application", wantErr: "ambiguous"},
+		{name: "ingresses", wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveResource(client, tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.GroupVersionResource.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.GroupVersionResource.String())
+			}
+		})
+	}
+}
@@ -8,16 +8,28 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// CreateClients creates a kubernetes clientSet for each context provided in `contexts`. Uses
+// Client groups the typed, dynamic and discovery clients of a single kubernetes context
+type Client struct {
+	Clientset kubernetes.Interface
+	Dynamic   dynamic.Interface
+	Discovery discovery.CachedDiscoveryInterface
+}
+
+// CreateClients creates a kubernetes client for each context provided in `contexts`. Uses
 // the kubeconfig file provided by `kubeconfig`
-func CreateClients(kubeconfig string, contexts []string) (map[string]*kubernetes.Clientset, error) {
-	clients := make(map[string]*kubernetes.Clientset, len(contexts))
+func CreateClients(kubeconfig string, contexts []string) (map[string]*Client, error) {
+	clients := make(map[string]*Client, len(contexts))
 	for _, context := range contexts {
 		k8sClient, err := CreateClient(kubeconfig, context)
 		if err != nil {
@@ -28,9 +40,9 @@ func CreateClients(kubeconfig string, contexts []string) (map[string]*kubernetes
 	return clients, nil
 }
 
-// CreateClient creates a kubernetes clientSet for the context provided in `context`. Uses
+// CreateClient creates a kubernetes client for the context provided in `context`. Uses
 // the kubeconfig file provided by `kubeconfig`
-func CreateClient(kubeconfig, context string) (*kubernetes.Clientset, error) {
+func CreateClient(kubeconfig, context string) (*Client, error) {
 	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
 		&clientcmd.ConfigOverrides{CurrentContext: context}).
@@ -43,7 +55,29 @@ func CreateClient(kubeconfig, context string) (*kubernetes.Clientset, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %v", err)
 	}
-	return clientset, nil
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+	return &Client{
+		Clientset: clientset,
+		Dynamic:   dynamicClient,
+		// discovery results are cached so resources are only resolved once per run
+		Discovery: memory.NewMemCacheClient(clientset.Discovery()),
+	}, nil
+}
+
+// DefaultNamespace returns the namespace of the current context of the kubeconfig file provided by
+// `kubeconfig`, or default if the context has no namespace or the file can not be loaded
+func DefaultNamespace(kubeconfig string) string {
+	namespace, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{}).
+		Namespace()
+	if err != nil || namespace == "" {
+		return v1.NamespaceDefault
+	}
+	return namespace
 }
 
 type ListDeploymentsOpts struct {
@@ -62,7 +96,7 @@ func ListDeployments(ctx context.Context, k kubernetes.Interface, opts ListDeplo
 		LabelSelector: joinLabels(opts.Labels),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
 	}
 	return deployList, nil
 }
@@ -83,7 +117,7 @@ func ListDaemonSets(ctx context.Context, k kubernetes.Interface, opts ListDaemon
 		LabelSelector: joinLabels(opts.Labels),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+		return nil, fmt.Errorf("failed to list daemonsets: %v", err)
 	}
 	return daemonSetList, nil
 }
@@ -104,7 +138,7 @@ func ListStatefulSets(ctx context.Context, k kubernetes.Interface, opts ListStat
 		LabelSelector: joinLabels(opts.Labels),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+		return nil, fmt.Errorf("failed to list statefulsets: %v", err)
 	}
 	return statefulSetList, nil
 }
@@ -126,11 +160,77 @@ func ListConfigMaps(ctx context.Context, k kubernetes.Interface, opts ListConfig
 		LabelSelector: joinLabels(opts.Labels),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+		return nil, fmt.Errorf("failed to list configmaps: %v", err)
 	}
 	return configmapList, nil
 }
 
+type ListSecretsOpts struct {
+	Namespace string
+	Labels    []string
+	Timeout   time.Duration
+}
+
+func ListSecrets(ctx context.Context, k kubernetes.Interface, opts ListSecretsOpts) (*corev1.SecretList, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	secretList, err := k.CoreV1().Secrets(opts.Namespace).List(ctx, v1.ListOptions{
+		LabelSelector: joinLabels(opts.Labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %v", err)
+	}
+	return secretList, nil
+}
+
+type ListHorizontalPodAutoscalersOpts struct {
+	Namespace string
+	Labels    []string
+	Timeout   time.Duration
+}
+
+func ListHorizontalPodAutoscalers(ctx context.Context, k kubernetes.Interface, opts ListHorizontalPodAutoscalersOpts) (*autoscalingv2.HorizontalPodAutoscalerList, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	hpaList, err := k.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace).List(ctx, v1.ListOptions{
+		LabelSelector: joinLabels(opts.Labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list horizontalpodautoscalers: %v", err)
+	}
+	return hpaList, nil
+}
+
+type ListPodDisruptionBudgetsOpts struct {
+	Namespace string
+	Labels    []string
+	Timeout   time.Duration
+}
+
+func ListPodDisruptionBudgets(ctx context.Context, k kubernetes.Interface, opts ListPodDisruptionBudgetsOpts) (*policyv1.PodDisruptionBudgetList, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	pdbList, err := k.PolicyV1().PodDisruptionBudgets(opts.Namespace).List(ctx, v1.ListOptions{
+		LabelSelector: joinLabels(opts.Labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list poddisruptionbudgets: %v", err)
+	}
+	return pdbList, nil
+}
+
 // joinLabels joins the labels into a single string
 func joinLabels(labels []string) string {
 	return strings.Join(labels, ",")
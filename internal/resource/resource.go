@@ -5,14 +5,24 @@ import (
 	"fmt"
 
 	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
-	"k8s.io/client-go/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// Apply aplies a function to every resource of a given resource type filtered by namespace and labels
-func Apply(ctx context.Context, client kubernetes.Interface, resourceType string, labels []string, namespace string, fn func(item any, name string) error) error {
+// Meta identifies an object
+type Meta struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// Apply aplies a function to every resource of a given resource type filtered by namespace and labels.
+// Deployments, daemonsets, statefulsets, configmaps, secrets, horizontalpodautoscalers and poddisruptionbudgets
+// are passed to fn as typed objects, with the kind and apiVersion that list items do not have, every other
+// resource is resolved with the discovery API and passed to fn as an unstructured object content
+func Apply(ctx context.Context, client *k8s.Client, resourceType string, labels []string, namespace string, fn func(item any, meta Meta) error) error {
 	switch resourceType {
-	case "deployment", "deploy":
-		resources, err := k8s.ListDeployments(ctx, client, k8s.ListDeploymentsOpts{
+	case "deployment", "deployments", "deploy":
+		resources, err := k8s.ListDeployments(ctx, client.Clientset, k8s.ListDeploymentsOpts{
 			Namespace: namespace,
 			Labels:    labels,
 		})
@@ -20,13 +30,14 @@ func Apply(ctx context.Context, client kubernetes.Interface, resourceType string
 			return err
 		}
 		for _, deploy := range resources.Items {
-			err := fn(deploy, deploy.Name)
+			deploy.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+			err := fn(deploy, Meta{Kind: "Deployment", Namespace: deploy.Namespace, Name: deploy.Name})
 			if err != nil {
 				return err
 			}
 		}
-	case "daemonset", "ds":
-		resources, err := k8s.ListDaemonSets(ctx, client, k8s.ListDaemonSetsOpts{
+	case "daemonset", "daemonsets", "ds":
+		resources, err := k8s.ListDaemonSets(ctx, client.Clientset, k8s.ListDaemonSetsOpts{
 			Namespace: namespace,
 			Labels:    labels,
 		})
@@ -34,27 +45,29 @@ func Apply(ctx context.Context, client kubernetes.Interface, resourceType string
 			return err
 		}
 		for _, ds := range resources.Items {
-			err := fn(ds, ds.Name)
+			ds.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"}
+			err := fn(ds, Meta{Kind: "DaemonSet", Namespace: ds.Namespace, Name: ds.Name})
 			if err != nil {
 				return err
 			}
 		}
-	case "statefulset", "sts":
-		resources, err := k8s.ListStatefulSets(ctx, client, k8s.ListStatefulSetsOpts{
+	case "statefulset", "statefulsets", "sts":
+		resources, err := k8s.ListStatefulSets(ctx, client.Clientset, k8s.ListStatefulSetsOpts{
 			Namespace: namespace,
 			Labels:    labels,
 		})
 		if err != nil {
 			return err
 		}
-		for _, ds := range resources.Items {
-			err := fn(ds, ds.Name)
+		for _, sts := range resources.Items {
+			sts.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"}
+			err := fn(sts, Meta{Kind: "StatefulSet", Namespace: sts.Namespace, Name: sts.Name})
 			if err != nil {
 				return err
 			}
 		}
-	case "configmap", "cm":
-		resources, err := k8s.ListConfigMaps(ctx, client, k8s.ListConfigMapsOpts{
+	case "configmap", "configmaps", "cm":
+		resources, err := k8s.ListConfigMaps(ctx, client.Clientset, k8s.ListConfigMapsOpts{
 			Namespace: namespace,
 			Labels:    labels,
 		})
@@ -62,13 +75,75 @@ func Apply(ctx context.Context, client kubernetes.Interface, resourceType string
 			return err
 		}
 		for _, cm := range resources.Items {
-			err := fn(cm, cm.Name)
+			cm.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+			err := fn(cm, Meta{Kind: "ConfigMap", Namespace: cm.Namespace, Name: cm.Name})
+			if err != nil {
+				return err
+			}
+		}
+	case "secret", "secrets":
+		resources, err := k8s.ListSecrets(ctx, client.Clientset, k8s.ListSecretsOpts{
+			Namespace: namespace,
+			Labels:    labels,
+		})
+		if err != nil {
+			return err
+		}
+		for _, secret := range resources.Items {
+			secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+			err := fn(secret, Meta{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name})
+			if err != nil {
+				return err
+			}
+		}
+	case "horizontalpodautoscaler", "horizontalpodautoscalers", "hpa":
+		resources, err := k8s.ListHorizontalPodAutoscalers(ctx, client.Clientset, k8s.ListHorizontalPodAutoscalersOpts{
+			Namespace: namespace,
+			Labels:    labels,
+		})
+		if err != nil {
+			return err
+		}
+		for _, hpa := range resources.Items {
+			hpa.TypeMeta = metav1.TypeMeta{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler"}
+			err := fn(hpa, Meta{Kind: "HorizontalPodAutoscaler", Namespace: hpa.Namespace, Name: hpa.Name})
+			if err != nil {
+				return err
+			}
+		}
+	case "poddisruptionbudget", "poddisruptionbudgets", "pdb":
+		resources, err := k8s.ListPodDisruptionBudgets(ctx, client.Clientset, k8s.ListPodDisruptionBudgetsOpts{
+			Namespace: namespace,
+			Labels:    labels,
+		})
+		if err != nil {
+			return err
+		}
+		for _, pdb := range resources.Items {
+			pdb.TypeMeta = metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"}
+			err := fn(pdb, Meta{Kind: "PodDisruptionBudget", Namespace: pdb.Namespace, Name: pdb.Name})
 			if err != nil {
 				return err
 			}
 		}
 	default:
-		return fmt.Errorf("resource %s not supported", resourceType)
+		apiResource, err := k8s.ResolveResource(client.Discovery, resourceType)
+		if err != nil {
+			return fmt.Errorf("resource %s not supported: %v", resourceType, err)
+		}
+		resources, err := k8s.ListResources(ctx, client.Dynamic, apiResource, k8s.ListResourcesOpts{
+			Namespace: namespace,
+			Labels:    labels,
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range resources.Items {
+			err := fn(item.Object, Meta{Kind: apiResource.Kind, Namespace: item.GetNamespace(), Name: item.GetName()})
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
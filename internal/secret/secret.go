@@ -0,0 +1,152 @@
+// Package secret implements the redaction of secrets so they can be compared without revealing their values
+package secret
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+)
+
+// Kind is the kind of the objects redacted by Redact
+const Kind = "Secret"
+
+// FingerprintAnnotation is set on the secrets redacted by Redact to the algorithm of their fingerprints, so
+// secrets stored redacted, e.g. in a snapshot, are not fingerprinted again
+const FingerprintAnnotation = "kubediff.io/fingerprint"
+
+// fingerprintRegexp matches the fingerprints returned by Fingerprint
+var fingerprintRegexp = regexp.MustCompile(`^(sha256|hmac-sha256):[0-9a-f]{64}$`)
+
+// lastAppliedAnnotation is set by kubectl apply with the applied object, including the secret data
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Fingerprinter computes the fingerprints of secret values: the SHA-256 of the value or, when a key is
+// provided, the HMAC-SHA256 of the value with the key
+type Fingerprinter struct {
+	key []byte
+}
+
+// NewFingerprinter creates a Fingerprinter. An empty key uses SHA-256 without HMAC
+func NewFingerprinter(key []byte) *Fingerprinter {
+	return &Fingerprinter{key: key}
+}
+
+// Fingerprint returns the fingerprint of value prefixed by the algorithm, e.g. sha256:2cf24dba...
+func (f *Fingerprinter) Fingerprint(value []byte) string {
+	var h hash.Hash
+	if len(f.key) > 0 {
+		h = hmac.New(sha256.New, f.key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(value)
+	return f.Algorithm() + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Algorithm returns the algorithm of the fingerprints, sha256 or hmac-sha256
+func (f *Fingerprinter) Algorithm() string {
+	if len(f.key) > 0 {
+		return "hmac-sha256"
+	}
+	return "sha256"
+}
+
+// Redact returns the unstructured content of a secret with the values of data and stringData replaced by
+// their fingerprints. stringData is merged into data, as the API server does, so a manifest using stringData
+// matches the secret stored in the cluster. The last applied configuration annotation is also redacted and
+// FingerprintAnnotation is set. Secrets that are already redacted, whose values are all fingerprints, are
+// returned as is if they were redacted with the same algorithm. obj is not modified
+func (f *Fingerprinter) Redact(obj any) (map[string]any, error) {
+	content, err := fieldpath.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	metadata, _ := content["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if algorithm, ok := annotations[FingerprintAnnotation]; ok && isRedacted(content, fmt.Sprint(algorithm)) {
+		if algorithm != f.Algorithm() {
+			return nil, fmt.Errorf("secret values were fingerprinted with %v, not %s, use the same HMAC key", algorithm, f.Algorithm())
+		}
+		return content, nil
+	}
+
+	redacted := make(map[string]any, len(content))
+	for key, value := range content {
+		redacted[key] = value
+	}
+	delete(redacted, "stringData")
+
+	data := make(map[string]any)
+	if m, ok := content["data"].(map[string]any); ok {
+		for key, value := range m {
+			data[key] = f.fingerprintEncoded(value)
+		}
+	}
+	if m, ok := content["stringData"].(map[string]any); ok {
+		for key, value := range m {
+			data[key] = f.Fingerprint([]byte(toString(value)))
+		}
+	}
+	if len(data) > 0 {
+		redacted["data"] = data
+	}
+
+	redactedAnnotations := make(map[string]any, len(annotations)+1)
+	for key, value := range annotations {
+		redactedAnnotations[key] = value
+	}
+	if lastApplied, ok := annotations[lastAppliedAnnotation].(string); ok {
+		redactedAnnotations[lastAppliedAnnotation] = f.Fingerprint([]byte(lastApplied))
+	}
+	redactedAnnotations[FingerprintAnnotation] = f.Algorithm()
+	redactedMetadata := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		redactedMetadata[key] = value
+	}
+	redactedMetadata["annotations"] = redactedAnnotations
+	redacted["metadata"] = redactedMetadata
+	return redacted, nil
+}
+
+// isRedacted checks if the values of a secret are all fingerprints of an algorithm, so a secret with the
+// annotation but with values is still redacted
+func isRedacted(content map[string]any, algorithm string) bool {
+	if _, ok := content["stringData"]; ok {
+		return false
+	}
+	data, _ := content["data"].(map[string]any)
+	for _, value := range data {
+		s, ok := value.(string)
+		if !ok || !fingerprintRegexp.MatchString(s) || !strings.HasPrefix(s, algorithm+":") {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprintEncoded returns the fingerprint of a base64 encoded value of data. Values that are not valid
+// base64 are fingerprinted as is so they are still never revealed
+func (f *Fingerprinter) fingerprintEncoded(value any) string {
+	s := toString(value)
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return f.Fingerprint([]byte(s))
+	}
+	return f.Fingerprint(decoded)
+}
+
+// toString returns the string of a value, manifests may have values decoded as other types such as
+// y decoded as a boolean
+func toString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
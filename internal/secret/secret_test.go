@@ -0,0 +1,145 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		want string
+	}{
+		{
+			name: "sha256",
+			want: "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+		{
+			name: "hmac-sha256",
+			key:  []byte("key"),
+			want: "hmac-sha256:9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewFingerprinter(tt.key).Fingerprint([]byte("hello")); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	f := NewFingerprinter(nil)
+	hello := f.Fingerprint([]byte("hello"))
+	tests := []struct {
+		name     string
+		obj      any
+		wantData map[string]any
+		wantErr  bool
+	}{
+		{
+			name: "data is decoded before it is fingerprinted",
+			obj: map[string]any{
+				"kind": "Secret",
+				"data": map[string]any{"password": "aGVsbG8="},
+			},
+			wantData: map[string]any{"password": hello},
+		},
+		{
+			name: "stringData is merged into data",
+			obj: map[string]any{
+				"kind":       "Secret",
+				"stringData": map[string]any{"password": "hello"},
+			},
+			wantData: map[string]any{"password": hello},
+		},
+		{
+			name: "typed secrets are redacted",
+			obj: &corev1.Secret{
+				Data: map[string][]byte{"password": []byte("hello")},
+			},
+			wantData: map[string]any{"password": hello},
+		},
+		{
+			name: "values that are not base64 are fingerprinted as is",
+			obj: map[string]any{
+				"kind": "Secret",
+				"data": map[string]any{"password": "hello"},
+			},
+			wantData: map[string]any{"password": hello},
+		},
+		{
+			name: "redacted secrets are returned as is",
+			obj: map[string]any{
+				"kind":     "Secret",
+				"metadata": map[string]any{"annotations": map[string]any{FingerprintAnnotation: "sha256"}},
+				"data":     map[string]any{"password": hello},
+			},
+			wantData: map[string]any{"password": hello},
+		},
+		{
+			name: "secrets redacted with another algorithm fail",
+			obj: map[string]any{
+				"kind":     "Secret",
+				"metadata": map[string]any{"annotations": map[string]any{FingerprintAnnotation: "hmac-sha256"}},
+				"data":     map[string]any{"password": NewFingerprinter([]byte("key")).Fingerprint([]byte("hello"))},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Redact(tt.obj)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := got["stringData"]; ok {
+				t.Errorf("expected stringData to be removed")
+			}
+			data, _ := got["data"].(map[string]any)
+			if len(data) != len(tt.wantData) {
+				t.Fatalf("expected data %v, got %v", tt.wantData, data)
+			}
+			for key, value := range tt.wantData {
+				if data[key] != value {
+					t.Errorf("expected %s to be %v, got %v", key, value, data[key])
+				}
+			}
+			annotations := got["metadata"].(map[string]any)["annotations"].(map[string]any)
+			if annotations[FingerprintAnnotation] != "sha256" {
+				t.Errorf("expected the fingerprint annotation sha256, got %v", annotations[FingerprintAnnotation])
+			}
+		})
+	}
+}
+
+func TestRedactLastApplied(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			lastAppliedAnnotation: `{"stringData":{"password":"hello"}}`,
+		}},
+	}
+	got, err := NewFingerprinter(nil).Redact(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := got["metadata"].(map[string]any)["annotations"].(map[string]any)
+	lastApplied, _ := annotations[lastAppliedAnnotation].(string)
+	if strings.Contains(lastApplied, "hello") || !strings.HasPrefix(lastApplied, "sha256:") {
+		t.Errorf("expected the last applied configuration to be fingerprinted, got %s", lastApplied)
+	}
+	if secret.Annotations[lastAppliedAnnotation] == lastApplied {
+		t.Errorf("expected the secret not to be modified")
+	}
+}
@@ -0,0 +1,222 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
+	"github.com/eduardodbr/kubediff/internal/resource"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// shortNames maps the short names of the built-in resources to their plural, there is no
+// discovery API to resolve them when reading manifests
+var shortNames = map[string]string{
+	"cj":     "cronjobs",
+	"cm":     "configmaps",
+	"crd":    "customresourcedefinitions",
+	"deploy": "deployments",
+	"ds":     "daemonsets",
+	"ep":     "endpoints",
+	"hpa":    "horizontalpodautoscalers",
+	"ing":    "ingresses",
+	"limits": "limitranges",
+	"netpol": "networkpolicies",
+	"ns":     "namespaces",
+	"pc":     "priorityclasses",
+	"pdb":    "poddisruptionbudgets",
+	"po":     "pods",
+	"pv":     "persistentvolumes",
+	"pvc":    "persistentvolumeclaims",
+	"quota":  "resourcequotas",
+	"rs":     "replicasets",
+	"sa":     "serviceaccounts",
+	"sc":     "storageclasses",
+	"sts":    "statefulsets",
+	"svc":    "services",
+}
+
+// clusterScopedKinds are the kinds of the built-in cluster scoped resources, which never get a namespace
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// Manifests is a source that reads the objects from YAML or JSON manifests
+type Manifests struct {
+	objects []*unstructured.Unstructured
+	// defaultNamespace is the namespace of the objects without namespace when no namespace is filtered
+	defaultNamespace string
+}
+
+// LoadManifests loads the manifests of a file or, if recursive is set, of every .yaml, .yml and .json
+// file of a directory and its subdirectories
+func LoadManifests(path string, recursive bool) (*Manifests, error) {
+	if !recursive {
+		return loadManifestsFile(path)
+	}
+	m := &Manifests{}
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fileManifests, err := loadManifestsFile(file)
+		if err != nil {
+			return err
+		}
+		m.objects = append(m.objects, fileManifests.objects...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifests from %s: %v", path, err)
+	}
+	return m, nil
+}
+
+func loadManifestsFile(path string) (*Manifests, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifests: %v", err)
+	}
+	defer f.Close()
+	return ReadManifests(f, path)
+}
+
+// ReadManifests reads a stream of YAML or JSON documents. Documents of kind List are expanded into their items.
+// name identifies the stream in the errors
+func ReadManifests(r io.Reader, name string) (*Manifests, error) {
+	m := &Manifests{}
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for i := 0; ; i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode document %d of %s: %v", i, name, err)
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			// empty document
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("failed to decode document %d of %s: %v", i, name, err)
+		}
+		if !obj.IsList() {
+			m.objects = append(m.objects, obj)
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			if u, ok := item.(*unstructured.Unstructured); ok {
+				m.objects = append(m.objects, u)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read list items of document %d of %s: %v", i, name, err)
+		}
+	}
+	return m, nil
+}
+
+// SetDefaultNamespace sets the namespace of the objects without namespace when no namespace is filtered, e.g.
+// the namespace of the kubeconfig context the manifests are applied to. The default is the default namespace
+func (m *Manifests) SetDefaultNamespace(namespace string) {
+	m.defaultNamespace = namespace
+}
+
+// Apply applies fn to every object of a given resource type filtered by namespace and labels. Objects without
+// namespace are considered to be in the namespace being filtered, as they would be when applied with kubectl -n,
+// or in the default namespace if no namespace is filtered. Cluster scoped objects are never filtered by namespace
+func (m *Manifests) Apply(_ context.Context, resourceType string, selector []string, namespace string, fn func(item any, meta resource.Meta) error) error {
+	group, version, name, err := k8s.ParseResourceName(resourceType)
+	if err != nil {
+		return err
+	}
+	labelSelector, err := labels.Parse(strings.Join(selector, ","))
+	if err != nil {
+		return fmt.Errorf("invalid labels: %v", err)
+	}
+	for _, obj := range m.objects {
+		if !matchesResource(obj.GroupVersionKind(), group, version, name) || !labelSelector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		clusterScoped := clusterScopedKinds[obj.GetKind()]
+		objNamespace := obj.GetNamespace()
+		if objNamespace == "" && !clusterScoped {
+			objNamespace = namespace
+			if objNamespace == "" {
+				objNamespace = m.namespace()
+			}
+		}
+		if namespace != "" && objNamespace != namespace && !clusterScoped {
+			continue
+		}
+		err := fn(obj.Object, resource.Meta{Kind: obj.GetKind(), Namespace: objNamespace, Name: obj.GetName()})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespace returns the namespace of the objects without namespace when no namespace is filtered
+func (m *Manifests) namespace() string {
+	if m.defaultNamespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return m.defaultNamespace
+}
+
+// matchesResource checks if an object kind matches a resource name parsed by k8s.ParseResourceName
+func matchesResource(gvk schema.GroupVersionKind, group, version, name string) bool {
+	if version != "" && (gvk.Version != version || gvk.Group != group) {
+		return false
+	}
+	if group != "" && gvk.Group != group && !strings.HasPrefix(gvk.Group, group+".") {
+		return false
+	}
+	plural, singular := meta.UnsafeGuessKindToResource(gvk)
+	if fullName, ok := shortNames[name]; ok {
+		name = fullName
+	}
+	return name == strings.ToLower(gvk.Kind) || name == plural.Resource || name == singular.Resource
+}
@@ -0,0 +1,105 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eduardodbr/kubediff/internal/resource"
+)
+
+const namespaceManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-namespace
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: in-web
+  namespace: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+`
+
+func TestManifestsApplyNamespaces(t *testing.T) {
+	tests := []struct {
+		name             string
+		resourceType     string
+		namespace        string
+		defaultNamespace string
+		want             []resource.Meta
+	}{
+		{
+			name:         "objects without namespace are in the default namespace",
+			resourceType: "configmaps",
+			want: []resource.Meta{
+				{Kind: "ConfigMap", Namespace: "default", Name: "no-namespace"},
+				{Kind: "ConfigMap", Namespace: "web", Name: "in-web"},
+			},
+		},
+		{
+			name:             "objects without namespace are in the namespace of the kubeconfig context",
+			resourceType:     "cm",
+			defaultNamespace: "team",
+			want: []resource.Meta{
+				{Kind: "ConfigMap", Namespace: "team", Name: "no-namespace"},
+				{Kind: "ConfigMap", Namespace: "web", Name: "in-web"},
+			},
+		},
+		{
+			name:         "objects without namespace are in the filtered namespace",
+			resourceType: "configmaps",
+			namespace:    "web",
+			want: []resource.Meta{
+				{Kind: "ConfigMap", Namespace: "web", Name: "no-namespace"},
+				{Kind: "ConfigMap", Namespace: "web", Name: "in-web"},
+			},
+		},
+		{
+			name:         "other namespaces are filtered",
+			resourceType: "configmaps",
+			namespace:    "api",
+			want: []resource.Meta{
+				{Kind: "ConfigMap", Namespace: "api", Name: "no-namespace"},
+			},
+		},
+		{
+			name:         "cluster scoped objects have no namespace",
+			resourceType: "clusterroles",
+			namespace:    "web",
+			want: []resource.Meta{
+				{Kind: "ClusterRole", Name: "reader"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ReadManifests(strings.NewReader(namespaceManifests), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.SetDefaultNamespace(tt.defaultNamespace)
+			var got []resource.Meta
+			err = m.Apply(context.Background(), tt.resourceType, nil, tt.namespace, func(_ any, meta resource.Meta) error {
+				got = append(got, meta)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
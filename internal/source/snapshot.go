@@ -0,0 +1,175 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SnapshotAPIVersion is the version of the snapshot format
+	SnapshotAPIVersion = "kubediff/v1"
+	// SnapshotKind is the kind of the snapshot metadata
+	SnapshotKind = "Snapshot"
+	// snapshotMetadataFile is the file of the archive with the snapshot metadata
+	snapshotMetadataFile = "snapshot.yaml"
+	// snapshotObjectsDir is the directory of the archive with a file for each object
+	snapshotObjectsDir = "objects/"
+	// clusterScopedDir is the directory of the objects without namespace
+	clusterScopedDir = "_cluster"
+)
+
+// SnapshotMetadata describes a snapshot
+type SnapshotMetadata struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Context    string    `json:"context"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Resources  []string  `json:"resources"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Labels     []string  `json:"labels,omitempty"`
+}
+
+// IsSnapshot checks if a path is a snapshot archive by its extension, .tar.gz or .tgz
+func IsSnapshot(location string) bool {
+	return strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz")
+}
+
+// WriteSnapshot writes a gzip compressed tar archive with the metadata and a YAML file for each object, in
+// objects/<namespace>/<kind>.<group>/<name>.yaml. Objects are written in a stable order
+func WriteSnapshot(w io.Writer, metadata SnapshotMetadata, objects []map[string]any) error {
+	metadata.APIVersion, metadata.Kind = SnapshotAPIVersion, SnapshotKind
+	files := make(map[string][]byte, len(objects)+1)
+	content, err := yaml.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot metadata: %v", err)
+	}
+	files[snapshotMetadataFile] = content
+	for _, object := range objects {
+		obj := &unstructured.Unstructured{Object: object}
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = clusterScopedDir
+		}
+		kind := strings.ToLower(obj.GetKind())
+		if group := obj.GroupVersionKind().Group; group != "" {
+			kind += "." + group
+		}
+		name := path.Join(snapshotObjectsDir, namespace, kind, obj.GetName()+".yaml")
+		if _, ok := files[name]; ok {
+			return fmt.Errorf("object %s is duplicated", name)
+		}
+		content, err := yaml.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		files[name] = content
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != snapshotMetadataFile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	// the metadata is the first file so the archive is identified without reading the objects
+	names = append([]string{snapshotMetadataFile}, names...)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(files[name])),
+			ModTime: metadata.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return nil
+}
+
+// LoadSnapshot loads the objects of a snapshot archive written by WriteSnapshot
+func LoadSnapshot(location string) (*Manifests, *SnapshotMetadata, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %v", err)
+	}
+	defer f.Close()
+	m, metadata, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot %s: %v", location, err)
+	}
+	return m, metadata, nil
+}
+
+// ReadSnapshot reads a snapshot archive written by WriteSnapshot
+func ReadSnapshot(r io.Reader) (*Manifests, *SnapshotMetadata, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a snapshot archive: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	m := &Manifests{}
+	var metadata *SnapshotMetadata
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", header.Name, err)
+		}
+		switch {
+		case header.Name == snapshotMetadataFile:
+			metadata = &SnapshotMetadata{}
+			if err := yaml.Unmarshal(content, metadata); err != nil {
+				return nil, nil, fmt.Errorf("invalid snapshot metadata: %v", err)
+			}
+			if metadata.APIVersion != SnapshotAPIVersion || metadata.Kind != SnapshotKind {
+				return nil, nil, fmt.Errorf("unsupported snapshot %s %s, expected %s %s", metadata.APIVersion, metadata.Kind, SnapshotAPIVersion, SnapshotKind)
+			}
+		case strings.HasPrefix(header.Name, snapshotObjectsDir):
+			objects, err := ReadManifests(bytes.NewReader(content), header.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			m.objects = append(m.objects, objects.objects...)
+		}
+	}
+	if metadata == nil {
+		return nil, nil, fmt.Errorf("missing %s, not a snapshot archive", snapshotMetadataFile)
+	}
+	return m, metadata, nil
+}
@@ -0,0 +1,133 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eduardodbr/kubediff/internal/resource"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	objects := []map[string]any{
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": "api", "namespace": "web"},
+			"spec":       map[string]any{"replicas": int64(3)},
+		},
+		{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   map[string]any{"name": "reader"},
+		},
+	}
+	metadata := SnapshotMetadata{
+		Context:   "production",
+		CreatedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Resources: []string{"deployments", "clusterroles"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, metadata, objects); err != nil {
+		t.Fatal(err)
+	}
+
+	m, got, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.APIVersion != SnapshotAPIVersion || got.Kind != SnapshotKind {
+		t.Errorf("expected %s %s, got %s %s", SnapshotAPIVersion, SnapshotKind, got.APIVersion, got.Kind)
+	}
+	if got.Context != metadata.Context || !got.CreatedAt.Equal(metadata.CreatedAt) || len(got.Resources) != 2 {
+		t.Errorf("expected metadata %+v, got %+v", metadata, got)
+	}
+
+	tests := []struct {
+		resourceType string
+		want         resource.Meta
+	}{
+		{resourceType: "deployments", want: resource.Meta{Kind: "Deployment", Namespace: "web", Name: "api"}},
+		{resourceType: "clusterroles", want: resource.Meta{Kind: "ClusterRole", Name: "reader"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			var metas []resource.Meta
+			err := m.Apply(context.Background(), tt.resourceType, nil, "", func(_ any, meta resource.Meta) error {
+				metas = append(metas, meta)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(metas) != 1 || metas[0] != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, metas)
+			}
+		})
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content func() []byte
+		wantErr string
+	}{
+		{
+			name:    "not an archive",
+			content: func() []byte { return []byte("kind: Deployment") },
+			wantErr: "not a snapshot archive",
+		},
+		{
+			name: "unsupported version",
+			content: func() []byte {
+				var buf bytes.Buffer
+				writeArchive(t, &buf, map[string]string{snapshotMetadataFile: "apiVersion: kubediff/v2\nkind: Snapshot\n"})
+				return buf.Bytes()
+			},
+			wantErr: "unsupported snapshot kubediff/v2 Snapshot",
+		},
+		{
+			name: "missing metadata",
+			content: func() []byte {
+				var buf bytes.Buffer
+				writeArchive(t, &buf, map[string]string{snapshotObjectsDir + "web/deployment.apps/api.yaml": "kind: Deployment\n"})
+				return buf.Bytes()
+			},
+			wantErr: "missing " + snapshotMetadataFile,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadSnapshot(bytes.NewReader(tt.content()))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// writeArchive writes a gzip compressed tar archive with files
+func writeArchive(t *testing.T, w io.Writer, files map[string]string) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package source implements the sources of the objects to compare: kubernetes contexts and manifest files
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
+	"github.com/eduardodbr/kubediff/internal/resource"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Types of sources
+const (
+	// TypeContext reads the objects from a kubernetes context
+	TypeContext = "ctx"
+	// TypeFile reads the objects from a YAML or JSON file, - reads from stdin
+	TypeFile = "file"
+	// TypeDir reads the objects from the YAML and JSON files of a directory and its subdirectories
+	TypeDir = "dir"
+	// TypeStdin reads the objects from stdin
+	TypeStdin = "stdin"
+	// TypeSnapshot reads the objects from a snapshot archive written by WriteSnapshot
+	TypeSnapshot = "snapshot"
+)
+
+// Source provides the objects to compare
+type Source interface {
+	// Apply applies fn to every object of a given resource type filtered by namespace and labels
+	Apply(ctx context.Context, resourceType string, labels []string, namespace string, fn func(item any, meta resource.Meta) error) error
+}
+
+// Spec describes a source in the format <name>=<type>:<location>, e.g. staging=ctx:staging or desired=dir:./manifests
+type Spec struct {
+	Name     string
+	Type     string
+	Location string
+}
+
+// ParseSpec parses a source in the format <name>=<type>:<location>
+func ParseSpec(s string) (Spec, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return Spec{}, fmt.Errorf("invalid source %q, expected <name>=<type>:<location>", s)
+	}
+	sourceType, location, _ := strings.Cut(value, ":")
+	spec := Spec{Name: name, Type: sourceType, Location: location}
+	switch spec.Type {
+	case TypeContext, TypeFile, TypeDir, TypeSnapshot:
+		if spec.Location == "" {
+			return Spec{}, fmt.Errorf("invalid source %q, missing location", s)
+		}
+	case TypeStdin:
+	default:
+		return Spec{}, fmt.Errorf("invalid source %q, type must be one of %s, %s, %s, %s or %s", s, TypeContext, TypeFile, TypeDir, TypeStdin, TypeSnapshot)
+	}
+	return spec, nil
+}
+
+// New creates the source described by spec. Uses the kubeconfig file provided by `kubeconfig` for context sources
+func New(spec Spec, kubeconfig string) (Source, error) {
+	switch spec.Type {
+	case TypeContext:
+		client, err := k8s.CreateClient(kubeconfig, spec.Location)
+		if err != nil {
+			return nil, err
+		}
+		return NewCluster(client), nil
+	case TypeFile, TypeDir, TypeStdin:
+		var m *Manifests
+		var err error
+		switch {
+		case spec.Type == TypeStdin, spec.Location == "-":
+			m, err = ReadManifests(os.Stdin, "stdin")
+		default:
+			m, err = LoadManifests(spec.Location, spec.Type == TypeDir)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// objects without namespace are in the namespace kubectl apply would use
+		m.SetDefaultNamespace(k8s.DefaultNamespace(kubeconfig))
+		return m, nil
+	case TypeSnapshot:
+		m, _, err := LoadSnapshot(spec.Location)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported source type %s", spec.Type)
+}
+
+// Cluster is a source that reads the objects from a kubernetes cluster
+type Cluster struct {
+	client *k8s.Client
+}
+
+// NewCluster creates a source that reads the objects using client
+func NewCluster(client *k8s.Client) *Cluster {
+	return &Cluster{client: client}
+}
+
+// ServerVersion returns the Kubernetes version of the cluster, e.g. v1.31.3. The request is cancelled with ctx
+func (c *Cluster) ServerVersion(ctx context.Context) (string, error) {
+	rest := c.client.Discovery.RESTClient()
+	if rest == nil {
+		// fake discovery clients have no REST client
+		info, err := c.client.Discovery.ServerVersion()
+		if err != nil {
+			return "", err
+		}
+		return info.GitVersion, nil
+	}
+	body, err := rest.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the server version: %v", err)
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("failed to decode the server version: %v", err)
+	}
+	return info.GitVersion, nil
+}
+
+func (c *Cluster) Apply(ctx context.Context, resourceType string, labels []string, namespace string, fn func(item any, meta resource.Meta) error) error {
+	return resource.Apply(ctx, c.client, resourceType, labels, namespace, fn)
+}
@@ -0,0 +1,52 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
+	"github.com/eduardodbr/kubediff/internal/resource"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const manifestWithoutNamespace = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 2
+`
+
+// TestManifestsPairWithLiveObjects checks that a manifest without namespace is identified as the object
+// kubectl apply would create, so it is paired with it
+func TestManifestsPairWithLiveObjects(t *testing.T) {
+	live := NewCluster(&k8s.Client{Clientset: fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+	})})
+	git, err := ReadManifests(strings.NewReader(manifestWithoutNamespace), "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, namespace := range []string{"", "default"} {
+		metas := make(map[string][]resource.Meta)
+		for name, src := range map[string]Source{"git": git, "live": live} {
+			err := src.Apply(context.Background(), "deployments", nil, namespace, func(_ any, meta resource.Meta) error {
+				metas[name] = append(metas[name], meta)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := []resource.Meta{{Kind: "Deployment", Namespace: "default", Name: "api"}}
+		for name, got := range metas {
+			if len(got) != 1 || got[0] != want[0] {
+				t.Errorf("namespace %q: expected %v in %s, got %v", namespace, want, name, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	"github.com/eduardodbr/kubediff/internal/image"
+)
+
+// CompareValues compares the values of a resource in two contexts. Elements are matched by key when possible,
+// e.g. containers by name, so an extra element does not hide the differences of the remaining ones
+func CompareValues(source, target []any) ([]Change, error) {
+	return diff.Compare(source, target), nil
+}
+
+// Parts of the image references compared by CompareImageReferences
+const (
+	// ImageCompareReference compares the whole reference: repository, tag and digest
+	ImageCompareReference = ""
+	// ImageCompareDigest compares the repository and the digest of references pinned to a digest, and the
+	// repository and tag of the remaining
+	ImageCompareDigest = "digest"
+	// ImageCompareTag compares the repository and the tag
+	ImageCompareTag = "tag"
+	// ImageCompareRepository compares the repository
+	ImageCompareRepository = "repository"
+)
+
+// ImageOptions are the options of CompareImageReferences
+type ImageOptions struct {
+	// Compare is the part of the references compared, one of the ImageCompare constants
+	Compare string
+	// IgnoreRegistry compares the references without the registry, e.g. mirror.corp/nginx is equal to nginx
+	IgnoreRegistry bool
+	// IgnoreTag compares the references without the tag
+	IgnoreTag bool
+	// RegistryAliases maps registry prefixes to the registry they mirror, e.g. mirror.corp/ to docker.io/.
+	// Prefixes match the normalized name of the references, e.g. docker.io/library/nginx
+	RegistryAliases map[string]string
+}
+
+// CompareImages returns a function that compares the images of a resource in two contexts, ignoring the
+// container registry if ignoreRegistry is set
+func CompareImages(ignoreRegistry bool) CompareFunc {
+	return CompareImageReferences(ImageOptions{IgnoreRegistry: ignoreRegistry})
+}
+
+// ResolveImages resolves containers to their name and image, e.g. {name: app, image: nginx:1.27}, so
+// CompareImageReferences matches the images by container name
+func ResolveImages(_ context.Context, _ string, _ Source, _ Meta, vals []any) ([]any, error) {
+	resolved := make([]any, 0, len(vals))
+	for _, val := range vals {
+		container, err := toContainer(val)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, map[string]any{"name": container.Name, "image": container.Image})
+	}
+	return resolved, nil
+}
+
+// CompareImageReferences returns a function that compares the images of a resource in two contexts. The images
+// resolved by ResolveImages are matched by container name, so a reordered or added container does not hide the
+// differences of the remaining ones, and the remaining values by index. References are parsed and normalized,
+// so nginx is equal to docker.io/library/nginx:latest, and compared as set by opts. Images that are not valid
+// references are compared as strings
+func CompareImageReferences(opts ImageOptions) CompareFunc {
+	return func(source, target []any) ([]Change, error) {
+		sourceImages, sourceNames, sourceOK := containerImages(source)
+		targetImages, targetNames, targetOK := containerImages(target)
+		if !sourceOK || !targetOK {
+			return opts.compareByIndex(source, target), nil
+		}
+		// keep the order of the source followed by the containers only found in target
+		names := sourceNames
+		for _, name := range targetNames {
+			if _, ok := sourceImages[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		var changes []Change
+		for _, name := range names {
+			path := fmt.Sprintf("[name=%s].image", name)
+			s, inSource := sourceImages[name]
+			t, inTarget := targetImages[name]
+			switch {
+			case !inTarget:
+				changes = append(changes, Change{Type: Removed, Path: path, Source: s})
+			case !inSource:
+				changes = append(changes, Change{Type: Added, Path: path, Target: t})
+			case !opts.equal(s, t):
+				changes = append(changes, Change{Type: Changed, Path: path, Source: s, Target: t})
+			}
+		}
+		return changes, nil
+	}
+}
+
+// compareByIndex compares the images of two contexts by index
+func (o ImageOptions) compareByIndex(source, target []any) []Change {
+	var changes []Change
+	for i := 0; i < len(source) || i < len(target); i++ {
+		path := fmt.Sprintf("[%d]", i)
+		switch {
+		case i >= len(target):
+			changes = append(changes, Change{Type: Removed, Path: path, Source: source[i]})
+		case i >= len(source):
+			changes = append(changes, Change{Type: Added, Path: path, Target: target[i]})
+		case !o.equal(source[i], target[i]):
+			changes = append(changes, Change{Type: Changed, Path: path, Source: source[i], Target: target[i]})
+		}
+	}
+	return changes
+}
+
+// containerImages returns the images resolved by ResolveImages by container name and the names in order. Returns
+// false if any value is not a container with a unique name
+func containerImages(vals []any) (map[string]any, []string, bool) {
+	images := make(map[string]any, len(vals))
+	names := make([]string, 0, len(vals))
+	for _, val := range vals {
+		container, ok := val.(map[string]any)
+		if !ok {
+			return nil, nil, false
+		}
+		name, ok := container["name"].(string)
+		if _, duplicated := images[name]; !ok || duplicated {
+			return nil, nil, false
+		}
+		images[name] = container["image"]
+		names = append(names, name)
+	}
+	return images, names, true
+}
+
+// equal compares two images as set by the options
+func (o ImageOptions) equal(source, target any) bool {
+	s, sourceErr := image.Parse(fmt.Sprint(source))
+	t, targetErr := image.Parse(fmt.Sprint(target))
+	if sourceErr != nil || targetErr != nil {
+		return reflect.DeepEqual(source, target)
+	}
+	s, t = s.WithAliases(o.RegistryAliases), t.WithAliases(o.RegistryAliases)
+	if o.IgnoreRegistry {
+		// official images are in the library namespace only in the default registry, not in its mirrors
+		if s.ShortRepository() != t.ShortRepository() {
+			return false
+		}
+	} else if s.Registry != t.Registry || s.Repository != t.Repository {
+		return false
+	}
+	switch o.Compare {
+	case ImageCompareRepository:
+		return true
+	case ImageCompareDigest:
+		if s.Digest != "" && t.Digest != "" {
+			return s.Digest == t.Digest
+		}
+		return o.IgnoreTag || s.Tag == t.Tag
+	case ImageCompareTag:
+		return o.IgnoreTag || s.Tag == t.Tag
+	}
+	return (o.IgnoreTag || s.Tag == t.Tag) && s.Digest == t.Digest
+}
+
+// CompareDataKeys returns a function that compares the keys of the data, or binaryData, of a configmap or
+// secret in two contexts. The path of each change is the key. Keys matching any of the ignore glob
+// patterns, e.g. *.crt, are not compared
+func CompareDataKeys(ignore []string) CompareFunc {
+	return func(sourceData, targetData []any) ([]Change, error) {
+		source, target := toData(sourceData), toData(targetData)
+		keys := make([]string, 0, len(source)+len(target))
+		for key := range source {
+			keys = append(keys, key)
+		}
+		for key := range target {
+			if _, ok := source[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var changes []Change
+		for _, key := range keys {
+			if matchesAny(key, ignore) {
+				continue
+			}
+			s, inSource := source[key]
+			t, inTarget := target[key]
+			switch {
+			case !inTarget:
+				changes = append(changes, Change{Type: Removed, Path: key, Source: s})
+			case !inSource:
+				changes = append(changes, Change{Type: Added, Path: key, Target: t})
+			case s != t:
+				changes = append(changes, Change{Type: Changed, Path: key, Source: s, Target: t})
+			}
+		}
+		return changes, nil
+	}
+}
+
+// toData merges the data maps found in a configmap or secret path, there is at most one
+func toData(vals []any) map[string]any {
+	data := make(map[string]any)
+	for _, val := range vals {
+		if m, ok := val.(map[string]any); ok {
+			for key, value := range m {
+				data[key] = value
+			}
+		}
+	}
+	return data
+}
+
+// matchesAny checks if s matches any of the glob patterns
+func matchesAny(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
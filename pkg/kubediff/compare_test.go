@@ -0,0 +1,93 @@
+package kubediff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareImageReferences(t *testing.T) {
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	const otherDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	tests := []struct {
+		name   string
+		opts   ImageOptions
+		source string
+		target string
+		want   bool
+	}{
+		{name: "normalized references", source: "nginx", target: "docker.io/library/nginx:latest", want: true},
+		{name: "different tags", source: "nginx:1.27", target: "nginx:1.28"},
+		{name: "different registries", source: "mirror.corp/nginx:1", target: "nginx:1"},
+		{name: "ignore the registry of a mirror", opts: ImageOptions{IgnoreRegistry: true}, source: "mirror.corp/nginx:1", target: "nginx:1", want: true},
+		{name: "ignore the registry of a mirror of the library namespace", opts: ImageOptions{IgnoreRegistry: true}, source: "mirror.corp/library/nginx:1", target: "mirror.io/nginx:1", want: true},
+		{name: "ignore the registry of other repositories", opts: ImageOptions{IgnoreRegistry: true}, source: "mirror.corp/bitnami/redis:7", target: "bitnami/redis:7", want: true},
+		{name: "ignore the registry of different repositories", opts: ImageOptions{IgnoreRegistry: true}, source: "mirror.corp/redis:7", target: "bitnami/redis:7"},
+		{name: "registry alias", opts: ImageOptions{RegistryAliases: map[string]string{"mirror.corp/": "docker.io/"}}, source: "mirror.corp/nginx:1", target: "nginx:1", want: true},
+		{name: "ignore the tag", opts: ImageOptions{IgnoreTag: true}, source: "nginx:1.27", target: "nginx:1.28", want: true},
+		{name: "compare repositories", opts: ImageOptions{Compare: ImageCompareRepository}, source: "nginx:1.27@" + digest, target: "nginx:1.28", want: true},
+		{name: "compare digests", opts: ImageOptions{Compare: ImageCompareDigest}, source: "nginx:1.27@" + digest, target: "nginx:1.28@" + digest, want: true},
+		{name: "compare different digests", opts: ImageOptions{Compare: ImageCompareDigest}, source: "nginx@" + digest, target: "nginx@" + otherDigest},
+		{name: "compare tags without digests", opts: ImageOptions{Compare: ImageCompareDigest}, source: "nginx:1.27@" + digest, target: "nginx:1.27", want: true},
+		{name: "compare tags", opts: ImageOptions{Compare: ImageCompareTag}, source: "nginx:1.27@" + digest, target: "nginx:1.27@" + otherDigest, want: true},
+		{name: "invalid references are compared as strings", source: "my image", target: "my image", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := CompareImageReferences(tt.opts)([]any{tt.source}, []any{tt.target})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(changes) == 0; got != tt.want {
+				t.Errorf("expected equal %v, got changes %+v", tt.want, changes)
+			}
+		})
+	}
+}
+
+func TestCompareImageReferencesByContainer(t *testing.T) {
+	container := func(name, image string) any {
+		return map[string]any{"name": name, "image": image}
+	}
+	tests := []struct {
+		name   string
+		source []any
+		target []any
+		want   []Change
+	}{
+		{
+			name:   "reordered containers",
+			source: []any{container("app", "nginx:1.25"), container("tools", "busybox")},
+			target: []any{container("tools", "busybox"), container("app", "nginx:1.25")},
+		},
+		{
+			name:   "reordered and added containers",
+			source: []any{container("app", "nginx:1.25"), container("tools", "busybox")},
+			target: []any{container("proxy", "envoy"), container("app", "nginx:1.26")},
+			want: []Change{
+				{Type: Changed, Path: "[name=app].image", Source: "nginx:1.25", Target: "nginx:1.26"},
+				{Type: Removed, Path: "[name=tools].image", Source: "busybox"},
+				{Type: Added, Path: "[name=proxy].image", Target: "envoy"},
+			},
+		},
+		{
+			name:   "images without container are compared by index",
+			source: []any{"nginx:1.25", "busybox"},
+			target: []any{"envoy", "nginx:1.26"},
+			want: []Change{
+				{Type: Changed, Path: "[0]", Source: "nginx:1.25", Target: "envoy"},
+				{Type: Changed, Path: "[1]", Source: "busybox", Target: "nginx:1.26"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareImageReferences(ImageOptions{})(tt.source, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
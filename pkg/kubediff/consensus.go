@@ -0,0 +1,98 @@
+package kubediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// consensusDifferences compares the contexts against the value of the majority of the contexts. For each path
+// with differences, the contexts are grouped by value and the contexts that disagree with the largest group
+// are reported as outliers against the first context of that group. Ties are broken by the order of the contexts
+func (d *Differ) consensusDifferences(contexts []string, contextsMap map[string][]any) ([]Difference, error) {
+	// diffs between every ordered pair of contexts
+	diffs := make(map[[2]string][]Change)
+	var paths []string
+	seenPaths := make(map[string]bool)
+	for _, source := range contexts {
+		for _, target := range contexts {
+			if source == target {
+				continue
+			}
+			pairDiffs, err := d.compare(contextsMap[source], contextsMap[target])
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare %s and %s: %v", source, target, err)
+			}
+			diffs[[2]string{source, target}] = pairDiffs
+			for _, change := range pairDiffs {
+				if !seenPaths[change.Path] {
+					seenPaths[change.Path] = true
+					paths = append(paths, change.Path)
+				}
+			}
+		}
+	}
+
+	var result []Difference
+	reported := make(map[[2]string]bool)
+	for _, path := range paths {
+		reference := majorityContext(contexts, diffs, path)
+		for _, outlier := range contexts {
+			if outlier == reference {
+				continue
+			}
+			for _, change := range diffs[[2]string{reference, outlier}] {
+				key := [2]string{outlier, change.Path}
+				if !relatedPaths(change.Path, path) || reported[key] {
+					continue
+				}
+				reported[key] = true
+				result = append(result, Difference{
+					SourceContext: reference,
+					TargetContext: outlier,
+					Change:        change,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// majorityContext returns the first context of the largest group of contexts that agree on the value of path
+func majorityContext(contexts []string, diffs map[[2]string][]Change, path string) string {
+	majority, majoritySize := "", 0
+	for _, context := range contexts {
+		size := 1
+		for _, other := range contexts {
+			if other != context && !disagree(diffs[[2]string{context, other}], path) {
+				size++
+			}
+		}
+		if size > majoritySize {
+			majority, majoritySize = context, size
+		}
+	}
+	return majority
+}
+
+// disagree checks if there is a difference in path, in one of its parents or in one of its children
+func disagree(diffs []Change, path string) bool {
+	for _, change := range diffs {
+		if relatedPaths(change.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// relatedPaths checks if the paths are equal or one of them contains the other
+func relatedPaths(a, b string) bool {
+	return a == b || isParentPath(a, b) || isParentPath(b, a)
+}
+
+func isParentPath(parent, child string) bool {
+	if parent == "" {
+		return true
+	}
+	return strings.HasPrefix(child, parent+".") || strings.HasPrefix(child, parent+"[")
+}
@@ -0,0 +1,193 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+	"github.com/eduardodbr/kubediff/internal/secret"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// refResolver resolves the configmap and secret references of the env vars of containers with the values
+// found in each context. Secret values are resolved to their fingerprints
+type refResolver struct {
+	fingerprinter *secret.Fingerprinter
+	lock          sync.Mutex
+	// cache has the data of the configmaps and secrets of a namespace by name
+	cache map[refCacheKey]map[string]map[string]string
+}
+
+// refCacheKey identifies the configmaps or secrets of a context resolved to a namespace
+type refCacheKey struct {
+	context   string
+	kind      string
+	namespace string
+}
+
+// ResolveEnvRefs returns a function that resolves the configmap and secret references of the env vars of
+// containers with the values found in each context, so CompareEnvs compares the effective values. envFrom
+// is expanded into env vars. Secret values are resolved to their fingerprint, the SHA-256 of the value or,
+// if hmacKey is set, the HMAC-SHA256. References that can not be resolved are compared as references
+func ResolveEnvRefs(hmacKey []byte) ResolveFunc {
+	r := &refResolver{
+		fingerprinter: secret.NewFingerprinter(hmacKey),
+		cache:         make(map[refCacheKey]map[string]map[string]string),
+	}
+	return r.resolveContainers
+}
+
+// resolveContainers replaces the env vars set from configmaps and secrets by their values and expands envFrom
+// into env vars. References that can not be resolved are kept so they are compared as references
+func (r *refResolver) resolveContainers(ctx context.Context, context string, src Source, meta Meta, vals []any) ([]any, error) {
+	resolved := make([]any, 0, len(vals))
+	for _, val := range vals {
+		container, err := toContainer(val)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.resolveContainer(ctx, context, src, meta.Namespace, container); err != nil {
+			return nil, err
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(container)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, content)
+	}
+	return resolved, nil
+}
+
+func (r *refResolver) resolveContainer(ctx context.Context, context string, src Source, namespace string, container *corev1.Container) error {
+	var envs []corev1.EnvVar
+	// env vars override the env vars of envFrom, and later envFrom override the previous ones
+	setEnv := func(env corev1.EnvVar) {
+		for i := range envs {
+			if envs[i].Name == env.Name {
+				envs[i] = env
+				return
+			}
+		}
+		envs = append(envs, env)
+	}
+
+	var unresolved []corev1.EnvFromSource
+	for _, envFrom := range container.EnvFrom {
+		kind, name := "", ""
+		switch {
+		case envFrom.ConfigMapRef != nil:
+			kind, name = "configmap", envFrom.ConfigMapRef.Name
+		case envFrom.SecretRef != nil:
+			kind, name = "secret", envFrom.SecretRef.Name
+		}
+		data, ok, err := r.data(ctx, context, src, kind, namespace, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			unresolved = append(unresolved, envFrom)
+			continue
+		}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			setEnv(corev1.EnvVar{Name: envFrom.Prefix + key, Value: data[key]})
+		}
+	}
+
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			setEnv(env)
+			continue
+		}
+		kind, name, key := "", "", ""
+		switch {
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			kind, name, key = "configmap", env.ValueFrom.ConfigMapKeyRef.Name, env.ValueFrom.ConfigMapKeyRef.Key
+		case env.ValueFrom.SecretKeyRef != nil:
+			kind, name, key = "secret", env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Key
+		default:
+			// field references depend on the pod and can not be resolved
+			setEnv(env)
+			continue
+		}
+		data, ok, err := r.data(ctx, context, src, kind, namespace, name)
+		if err != nil {
+			return err
+		}
+		value, found := data[key]
+		if !ok || !found {
+			setEnv(env)
+			continue
+		}
+		setEnv(corev1.EnvVar{Name: env.Name, Value: value})
+	}
+
+	container.Env = envs
+	container.EnvFrom = unresolved
+	return nil
+}
+
+// data returns the data of a configmap or secret of a namespace. Returns false if it does not exist
+func (r *refResolver) data(ctx context.Context, context string, src Source, kind, namespace, name string) (map[string]string, bool, error) {
+	if kind == "" {
+		return nil, false, nil
+	}
+	key := refCacheKey{context: context, kind: kind, namespace: namespace}
+	r.lock.Lock()
+	objects, ok := r.cache[key]
+	r.lock.Unlock()
+	if !ok {
+		// each context is resolved by a single goroutine, so a namespace is never listed twice
+		listed, err := r.list(ctx, src, kind, namespace)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to resolve %s %s/%s: %v", kind, namespace, name, err)
+		}
+		// only the objects the source resolved to the namespace of the referencing object are cached, e.g.
+		// manifests without namespace are in the namespace assigned by the source
+		objects = listed[namespace]
+		if objects == nil {
+			objects = make(map[string]map[string]string)
+		}
+		r.lock.Lock()
+		r.cache[key] = objects
+		r.lock.Unlock()
+	}
+	data, ok := objects[name]
+	return data, ok, nil
+}
+
+// list returns the data of every configmap or secret of a namespace by namespace and name. Secrets are redacted
+func (r *refResolver) list(ctx context.Context, src Source, kind, namespace string) (map[string]map[string]map[string]string, error) {
+	objects := make(map[string]map[string]map[string]string)
+	err := src.Apply(ctx, kind, nil, namespace, func(item any, meta Meta) error {
+		var content map[string]any
+		var err error
+		if meta.Kind == secret.Kind {
+			content, err = r.fingerprinter.Redact(item)
+		} else {
+			content, err = fieldpath.ToUnstructured(item)
+		}
+		if err != nil {
+			return err
+		}
+		data := make(map[string]string)
+		if m, ok := content["data"].(map[string]any); ok {
+			for key, value := range m {
+				data[key] = fmt.Sprint(value)
+			}
+		}
+		if objects[meta.Namespace] == nil {
+			objects[meta.Namespace] = make(map[string]map[string]string)
+		}
+		objects[meta.Namespace][meta.Name] = data
+		return nil
+	})
+	return objects, err
+}
@@ -0,0 +1,72 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// configMap returns the manifest of a configmap with a key value, without namespace if namespace is empty
+func configMap(namespace, name, value string) string {
+	meta := fmt.Sprintf("  name: %s\n", name)
+	if namespace != "" {
+		meta += fmt.Sprintf("  namespace: %s\n", namespace)
+	}
+	return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n%sdata:\n  value: %s\n", meta, value)
+}
+
+func TestResolveEnvRefs(t *testing.T) {
+	sources := make(map[string]Source)
+	for context, docs := range map[string][]string{
+		"a": {configMap("web", "settings", "web"), configMap("jobs", "settings", "jobs")},
+		"b": {configMap("", "settings", "default")},
+	} {
+		src, err := ReadManifests(strings.NewReader(strings.Join(docs, "---\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[context] = src
+	}
+	container := map[string]any{
+		"name": "app",
+		"env": []any{map[string]any{
+			"name":      "VALUE",
+			"valueFrom": map[string]any{"configMapKeyRef": map[string]any{"name": "settings", "key": "value"}},
+		}},
+	}
+	// a single resolver is used so the namespaces share its cache
+	resolve := ResolveEnvRefs(nil)
+	tests := []struct {
+		name      string
+		context   string
+		namespace string
+		want      string
+	}{
+		{name: "configmap of the namespace of the object", context: "a", namespace: "jobs", want: "jobs"},
+		{name: "configmap with the same name in another namespace", context: "a", namespace: "web", want: "web"},
+		{name: "configmaps of other namespaces are not resolved", context: "a", namespace: "", want: ""},
+		{name: "missing configmap", context: "a", namespace: "payments", want: ""},
+		{name: "manifest without namespace", context: "b", namespace: "default", want: "default"},
+		{name: "manifest without namespace in the namespace of the object", context: "b", namespace: "web", want: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vals, err := resolve(context.Background(), tt.context, sources[tt.context], Meta{Kind: "Deployment", Namespace: tt.namespace, Name: "api"}, []any{container})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := toContainer(vals[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := resolved.Env[0]
+			if env.Value != tt.want {
+				t.Errorf("expected value %q, got %q", tt.want, env.Value)
+			}
+			if resolvedRef := env.ValueFrom == nil; resolvedRef != (tt.want != "") {
+				t.Errorf("expected the reference to be resolved: %t, got %t", tt.want != "", resolvedRef)
+			}
+		})
+	}
+}
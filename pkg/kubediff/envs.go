@@ -0,0 +1,143 @@
+package kubediff
+
+import (
+	"fmt"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CompareEnvs returns a function that compares the env vars of the containers with the same name in two
+// contexts. Env vars set from a reference are compared by the reference (e.g. configMapKeyRef:app/LOG_LEVEL)
+// unless the values are resolved first with ResolveEnvRefs. Env vars named in ignore are not compared
+func CompareEnvs(ignore []string) CompareFunc {
+	return func(sourceContainers, targetContainers []any) ([]Change, error) {
+		source, err := toContainers(sourceContainers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert source containers: %v", err)
+		}
+		target, err := toContainers(targetContainers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert target containers: %v", err)
+		}
+
+		var changes []Change
+		for _, sourceContainer := range source {
+			containerPath := fmt.Sprintf("containers[name=%s]", sourceContainer.Name)
+			targetContainer, ok := findContainer(target, sourceContainer.Name)
+			if !ok {
+				changes = append(changes, Change{Type: Removed, Path: containerPath})
+				continue
+			}
+			sourceEnvMap, targetEnvMap := comparableEnvsMap(sourceContainer.Env, targetContainer.Env, ignore)
+			for _, change := range diff.Compare(sourceEnvMap, targetEnvMap) {
+				change.Path = fmt.Sprintf("%s.env[name=%s]", containerPath, change.Path)
+				changes = append(changes, change)
+			}
+			for _, change := range diff.Compare(comparableEnvFromMap(sourceContainer.EnvFrom), comparableEnvFromMap(targetContainer.EnvFrom)) {
+				change.Path = fmt.Sprintf("%s.envFrom[%s]", containerPath, change.Path)
+				changes = append(changes, change)
+			}
+		}
+		for _, targetContainer := range target {
+			if _, ok := findContainer(source, targetContainer.Name); !ok {
+				changes = append(changes, Change{Type: Added, Path: fmt.Sprintf("containers[name=%s]", targetContainer.Name)})
+			}
+		}
+		return changes, nil
+	}
+}
+
+// toContainers converts the unstructured content of a list of containers to corev1.Container
+func toContainers(vals []any) ([]*corev1.Container, error) {
+	containers := make([]*corev1.Container, 0, len(vals))
+	for _, val := range vals {
+		container, err := toContainer(val)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+func findContainer(containers []*corev1.Container, name string) (*corev1.Container, bool) {
+	for _, container := range containers {
+		if container.Name == name {
+			return container, true
+		}
+	}
+	return nil, false
+}
+
+// toContainer converts the unstructured content of a container to corev1.Container
+func toContainer(val any) (*corev1.Container, error) {
+	content, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected container type %T", val)
+	}
+	container := &corev1.Container{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, container); err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// comparableEnvsMap creates two maps to be compared where the key is the env var name and the value is the env var
+// value or, for env vars set from a reference, the reference (see envVarSource)
+func comparableEnvsMap(source, target []corev1.EnvVar, ignore []string) (sourceMap map[string]any, targetMap map[string]any) {
+	return envsMap(source, ignore), envsMap(target, ignore)
+}
+
+func envsMap(envs []corev1.EnvVar, ignore []string) map[string]any {
+	m := make(map[string]any)
+	for _, env := range envs {
+		if stringInSlice(env.Name, ignore) {
+			continue
+		}
+		if env.ValueFrom != nil {
+			m[env.Name] = envVarSource(env.ValueFrom)
+			continue
+		}
+		m[env.Name] = env.Value
+	}
+	return m
+}
+
+// envVarSource returns a comparable description of the source of an env var value, e.g. configMapKeyRef:app/LOG_LEVEL
+func envVarSource(source *corev1.EnvVarSource) string {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("configMapKeyRef:%s/%s", source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key)
+	case source.SecretKeyRef != nil:
+		return fmt.Sprintf("secretKeyRef:%s/%s", source.SecretKeyRef.Name, source.SecretKeyRef.Key)
+	case source.FieldRef != nil:
+		return fmt.Sprintf("fieldRef:%s", source.FieldRef.FieldPath)
+	case source.ResourceFieldRef != nil:
+		ref := source.ResourceFieldRef.Resource
+		if source.ResourceFieldRef.ContainerName != "" {
+			ref = source.ResourceFieldRef.ContainerName + "/" + ref
+		}
+		if !source.ResourceFieldRef.Divisor.IsZero() {
+			ref = fmt.Sprintf("%s/%s", ref, source.ResourceFieldRef.Divisor.String())
+		}
+		return "resourceFieldRef:" + ref
+	}
+	return "unknown"
+}
+
+// comparableEnvFromMap creates a map to be compared where the key identifies the configmap or secret,
+// e.g. configMapRef=app, and the value is the prefix of the env vars
+func comparableEnvFromMap(envFrom []corev1.EnvFromSource) map[string]any {
+	m := make(map[string]any)
+	for _, source := range envFrom {
+		switch {
+		case source.ConfigMapRef != nil:
+			m["configMapRef="+source.ConfigMapRef.Name] = source.Prefix
+		case source.SecretRef != nil:
+			m["secretRef="+source.SecretRef.Name] = source.Prefix
+		}
+	}
+	return m
+}
@@ -0,0 +1,460 @@
+// Package kubediff compares kubernetes resources between contexts, such as clusters or manifests, and
+// returns the differences as a Report. It does not print anything, every error is returned
+package kubediff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+	"github.com/eduardodbr/kubediff/internal/secret"
+	log "github.com/sirupsen/logrus"
+)
+
+// Differ finds the differences between the resources of a set of contexts. Each context reads the
+// resources from a Source
+type Differ struct {
+	// Contexts are the names of the contexts to compare, in the order they are compared and reported
+	Contexts []string
+	// Sources are the sources of the resources of each context
+	Sources map[string]Source
+	// Selector selects the resources to compare
+	Selector Selector
+	// Paths are the JSONPaths of the values to compare, e.g. spec.template.spec.containers[*].image, or $
+	// to compare whole objects, see NormalizeObjects. Each path is compared separately
+	Paths []string
+	// Options configures the comparison
+	Options Options
+	// Compare compares the values of a resource in two contexts, defaults to CompareValues
+	Compare CompareFunc
+	// Resolve, if set, transforms the values of a resource found in a path before they are compared
+	Resolve ResolveFunc
+	// Logger logs the progress of the comparison, nothing is logged if not set
+	Logger log.FieldLogger
+}
+
+// Selector selects the resources to compare
+type Selector struct {
+	// Resources are the resource types, e.g. deployments, ingresses.networking.k8s.io or apps/v1/deployments
+	Resources []string
+	// Namespaces are the namespaces of the resources, an empty namespace selects every namespace
+	Namespaces []string
+	// Labels are the label selectors of the resources, e.g. app=web
+	Labels []string
+}
+
+// Options configures the comparison
+type Options struct {
+	// IgnoreNonExistent does not report the resources that do not exist in every context
+	IgnoreNonExistent bool
+	// Baseline, if set, compares every context only against the baseline context
+	Baseline string
+	// Consensus compares every context against the value of the majority of the contexts and only
+	// reports the outliers
+	Consensus bool
+	// ContinueOnError reports the errors reading or comparing resources in Report.Errors and compares the
+	// remaining resources, instead of failing. A context that fails to read a resource is not compared
+	ContinueOnError bool
+	// HMACKey is the key used to fingerprint the values of secrets with HMAC-SHA256, SHA-256 is used if empty
+	HMACKey []byte
+	// Rules declare the expected differences, which are reported in Report.Suppressed instead of
+	// Report.Resources. The first rule that expects a difference suppresses it
+	Rules []Rule
+	// NamespaceMappings maps the namespaces of each context to the namespaces the resources are paired by,
+	// e.g. {staging: {payments-stg: payments}} compares payments-stg in staging with payments in the other
+	// contexts. Selector.Namespaces are the namespaces the resources are paired by
+	NamespaceMappings map[string]map[string]string
+	// NameRewrites rewrite the names of the resources of each context before they are paired, the first
+	// rewrite whose pattern matches a name is applied
+	NameRewrites map[string][]NameRewrite
+}
+
+// CompareFunc compares the values found in a path of a resource in two contexts
+type CompareFunc func(source, target []any) ([]Change, error)
+
+// ResolveFunc transforms the values found in a path of a resource in a context. src is the source of the context
+type ResolveFunc func(ctx context.Context, context string, src Source, meta Meta, vals []any) ([]any, error)
+
+// validate validates the configuration of the differ
+func (d *Differ) validate() error {
+	if len(d.Contexts) < 2 {
+		return fmt.Errorf("at least two contexts are required")
+	}
+	seen := make(map[string]bool, len(d.Contexts))
+	for _, context := range d.Contexts {
+		if seen[context] {
+			return fmt.Errorf("context %s is used more than once", context)
+		}
+		seen[context] = true
+		if d.Sources[context] == nil {
+			return fmt.Errorf("context %s has no source", context)
+		}
+	}
+	if d.Options.Baseline != "" && d.Options.Consensus {
+		return fmt.Errorf("baseline and consensus can not be used together")
+	}
+	if d.Options.Baseline != "" && !seen[d.Options.Baseline] {
+		return fmt.Errorf("baseline %s is not one of the contexts", d.Options.Baseline)
+	}
+	if len(d.Paths) == 0 {
+		return fmt.Errorf("at least one path is required")
+	}
+	if len(d.Selector.Resources) == 0 {
+		return fmt.Errorf("at least one resource is required")
+	}
+	return nil
+}
+
+// Diff compares the resources of the contexts and returns a report with the resources that have differences.
+// The resources of the report are sorted by namespace, kind and name, so two runs with the same resources
+// return the same report. The errors of every context are returned joined or, if Options.ContinueOnError
+// is set, added to the report
+func (d *Differ) Diff(ctx context.Context) (*Report, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	paths := make([]*fieldpath.Path, 0, len(d.Paths))
+	for _, p := range d.Paths {
+		path, err := fieldpath.Parse(p)
+		if err != nil {
+			return nil, err
+		}
+		if path.Legacy() {
+			d.logger().Warnf("Path %s uses Go struct field names, which is deprecated and only works with deployments, daemonsets, statefulsets and configmaps. Use the JSON field names instead", p)
+		}
+		paths = append(paths, path)
+	}
+	rules, err := compileRules(d.Options.Rules)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := newMapping(d.Contexts, d.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Contexts:  d.Contexts,
+		Resources: []ResourceResult{},
+	}
+	fingerprinter := secret.NewFingerprinter(d.Options.HMACKey)
+	compared := make(map[Meta]bool)
+	for _, path := range paths {
+		results, suppressed, errs := d.findDifferences(ctx, path, fingerprinter, rules, mapping, compared)
+		if len(errs) > 0 && !d.Options.ContinueOnError {
+			return nil, joinErrors(errs)
+		}
+		report.Resources = append(report.Resources, results...)
+		report.Suppressed = append(report.Suppressed, suppressed...)
+		report.Errors = append(report.Errors, errs...)
+	}
+	for meta := range compared {
+		report.Compared = append(report.Compared, meta)
+	}
+	report.sort(d.Options.Consensus)
+	return report, nil
+}
+
+// findDifferences finds the differences between the resources in the contexts in a path. Resources are paired
+// by their namespace and name, as mapped by mapping, and added to compared. Only resources with differences are
+// returned, the differences expected by the rules are returned as suppressed. The errors of every context are collected,
+// when Options.ContinueOnError is not set the comparison stops after the first namespace and resource type
+// with errors
+func (d *Differ) findDifferences(ctx context.Context, path *fieldpath.Path, fingerprinter *secret.Fingerprinter, rules []rule, mapping *mapping, compared map[Meta]bool) ([]ResourceResult, []ResourceResult, []ResourceError) {
+	namespaces := d.Selector.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	var results, suppressed []ResourceResult
+	var errs []ResourceError
+	for _, namespace := range namespaces {
+		for _, resourceType := range d.Selector.Resources {
+			d.logger().WithField("namespace", namespace).WithField("resource", resourceType).WithField("path", path.String()).Infoln("Finding differences ...")
+			// m is a map where the key identifies the resource and the value is a map where the key is the context
+			// and the value is a slice of values being compared
+			m := make(map[Meta]map[string][]any)
+			// failed has the contexts that failed to read every resource, or a single resource, which are not compared
+			failed := make(map[Meta]map[string]bool)
+			// origins has the meta of the resource of each context paired by the key
+			origins := make(map[Meta]map[string]Meta)
+			var batchErrs []ResourceError
+			lock := sync.Mutex{}
+			// errors are collected from every context, so one context failing does not cancel the others
+			var wg sync.WaitGroup
+			for _, context := range d.Contexts {
+				context, src := context, d.Sources[context] // https://golang.org/doc/faq#closures_and_goroutines
+				namespace := mapping.contextNamespace(context, namespace)
+				newError := func(name string, err error) ResourceError {
+					return ResourceError{Context: context, Namespace: namespace, Resource: resourceType, Name: name, Path: path.String(), Message: err.Error()}
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					funcToApply := func(item any, meta Meta) error {
+						paired := mapping.meta(context, meta)
+						vals, err := d.values(ctx, context, src, meta, item, path, fingerprinter)
+						if err == nil {
+							err = addOrigin(&lock, origins, paired, context, meta)
+						}
+						if err != nil {
+							resourceErr := newError(meta.Name, err)
+							if !d.Options.ContinueOnError {
+								return resourceErr
+							}
+							lock.Lock()
+							batchErrs = append(batchErrs, resourceErr)
+							addToFailed(failed, paired, context)
+							lock.Unlock()
+							return nil
+						}
+						addToMap(&lock, m, paired, context, vals...)
+						return nil
+					}
+
+					err := src.Apply(ctx, resourceType, d.Selector.Labels, namespace, funcToApply)
+					if err == nil {
+						return
+					}
+					var resourceErr ResourceError
+					if !errors.As(err, &resourceErr) {
+						resourceErr = newError("", err)
+					}
+					lock.Lock()
+					batchErrs = append(batchErrs, resourceErr)
+					// the context can not be compared, its resources would be reported as missing
+					addToFailed(failed, Meta{}, context)
+					lock.Unlock()
+				}()
+			}
+			wg.Wait()
+			sortErrors(batchErrs, d.Contexts)
+			errs = append(errs, batchErrs...)
+			if len(errs) > 0 && !d.Options.ContinueOnError {
+				return nil, nil, errs
+			}
+
+			var compareErrs []ResourceError
+			for meta, contextsMap := range m {
+				compared[meta] = true
+				result, expected, err := d.compareResource(meta, path.String(), contextsMap, rules, func(context string) bool {
+					return failed[Meta{}][context] || failed[meta][context]
+				})
+				if err != nil {
+					compareErrs = append(compareErrs, ResourceError{Namespace: meta.Namespace, Resource: resourceType, Name: meta.Name, Path: path.String(), Message: err.Error()})
+					continue
+				}
+				result.Names = pairedNames(meta, origins[meta])
+				if result.HasDifferences() {
+					results = append(results, result)
+				}
+				if len(expected) > 0 {
+					suppressedResult := result
+					suppressedResult.Missing = nil
+					suppressedResult.Differences = expected
+					suppressed = append(suppressed, suppressedResult)
+				}
+			}
+			sortErrors(compareErrs, d.Contexts)
+			errs = append(errs, compareErrs...)
+			if len(errs) > 0 && !d.Options.ContinueOnError {
+				return nil, nil, errs
+			}
+		}
+	}
+	return results, suppressed, errs
+}
+
+// values returns the values of the path in an item of a context, resolved by the Resolve function.
+// Secrets are redacted first
+func (d *Differ) values(ctx context.Context, context string, src Source, meta Meta, item any, path *fieldpath.Path, fingerprinter *secret.Fingerprinter) ([]any, error) {
+	if meta.Kind == secret.Kind {
+		// secret values are replaced by their fingerprints so they are never reported
+		redacted, err := fingerprinter.Redact(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to redact secret: %v", err)
+		}
+		item = redacted
+	}
+	vals, err := path.Values(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value for path %s: %v", path, err)
+	}
+	if d.Resolve != nil {
+		vals, err = d.Resolve(ctx, context, src, meta, vals)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve values: %v", err)
+		}
+	}
+	return vals, nil
+}
+
+// addToFailed marks a context as failed for a resource, or for every resource if meta is empty
+func addToFailed(failed map[Meta]map[string]bool, meta Meta, context string) {
+	if _, ok := failed[meta]; !ok {
+		failed[meta] = make(map[string]bool)
+	}
+	failed[meta][context] = true
+}
+
+// compareResource compares the values of a resource in the contexts selected by the options and returns the
+// differences expected by the rules separately. The contexts that failed to read the resource are not compared
+func (d *Differ) compareResource(meta Meta, path string, contextsMap map[string][]any, rules []rule, failed func(context string) bool) (ResourceResult, []Difference, error) {
+	result := ResourceResult{
+		Kind:      meta.Kind,
+		Namespace: meta.Namespace,
+		Name:      meta.Name,
+		Path:      path,
+		Values:    contextsMap,
+	}
+	var found []string
+	for _, context := range d.Contexts {
+		if failed(context) {
+			continue
+		}
+		if _, ok := contextsMap[context]; !ok {
+			if !d.Options.IgnoreNonExistent {
+				result.Missing = append(result.Missing, context)
+			}
+			continue
+		}
+		found = append(found, context)
+	}
+
+	// changes of each pair of contexts, compared once
+	pairChanges := make(map[[2]string][]Change)
+	changes := func(pair [2]string) ([]Change, error) {
+		if c, ok := pairChanges[pair]; ok {
+			return c, nil
+		}
+		c, err := d.compare(contextsMap[pair[0]], contextsMap[pair[1]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s and %s: %v", pair[0], pair[1], err)
+		}
+		pairChanges[pair] = c
+		return c, nil
+	}
+	pairs := d.comparisonPairs(found)
+	if d.Options.Consensus {
+		diffs, err := d.consensusDifferences(found, contextsMap)
+		if err != nil {
+			return result, nil, err
+		}
+		result.Differences = diffs
+	} else {
+		for _, pair := range pairs {
+			c, err := changes(pair)
+			if err != nil {
+				return result, nil, err
+			}
+			for _, change := range c {
+				result.Differences = append(result.Differences, Difference{
+					SourceContext: pair[0],
+					TargetContext: pair[1],
+					Change:        change,
+				})
+			}
+		}
+	}
+	suppressed, err := applyRules(rules, meta, &result, pairs, changes)
+	if err != nil {
+		return result, nil, err
+	}
+	return result, suppressed, nil
+}
+
+// comparisonPairs returns the pairs of contexts to compare: the baseline with every other context or,
+// without baseline, every pair of contexts
+func (d *Differ) comparisonPairs(contexts []string) [][2]string {
+	var pairs [][2]string
+	if baseline := d.Options.Baseline; baseline != "" {
+		if !stringInSlice(baseline, contexts) {
+			return nil
+		}
+		for _, context := range contexts {
+			if context != baseline {
+				pairs = append(pairs, [2]string{baseline, context})
+			}
+		}
+		return pairs
+	}
+	// compare each context with all following contexts
+	for i := range contexts {
+		for j := i + 1; j < len(contexts); j++ {
+			pairs = append(pairs, [2]string{contexts[i], contexts[j]})
+		}
+	}
+	return pairs
+}
+
+func (d *Differ) compare(source, target []any) ([]Change, error) {
+	if d.Compare == nil {
+		return CompareValues(source, target)
+	}
+	return d.Compare(source, target)
+}
+
+func (d *Differ) logger() log.FieldLogger {
+	if d.Logger == nil {
+		return discardLogger
+	}
+	return d.Logger
+}
+
+var discardLogger = &log.Logger{Out: io.Discard, Formatter: new(log.TextFormatter), Hooks: make(log.LevelHooks), Level: log.PanicLevel}
+
+// addOrigin records the meta of the resource of a context paired by key. Two resources of the same context can
+// not be paired by the same key
+func addOrigin(lock *sync.Mutex, origins map[Meta]map[string]Meta, key Meta, context string, meta Meta) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := origins[key]; !ok {
+		origins[key] = make(map[string]Meta)
+	}
+	if other, ok := origins[key][context]; ok && other != meta {
+		return fmt.Errorf("%s/%s and %s/%s are both paired as %s/%s", other.Namespace, other.Name, meta.Namespace, meta.Name, key.Namespace, key.Name)
+	}
+	origins[key][context] = meta
+	return nil
+}
+
+// pairedNames returns the namespace and name of the resources of the contexts where they are not the key,
+// i.e. mapped or renamed
+func pairedNames(key Meta, origins map[string]Meta) map[string]string {
+	var names map[string]string
+	for context, meta := range origins {
+		if meta == key {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]string)
+		}
+		names[context] = meta.Name
+		if meta.Namespace != "" {
+			names[context] = meta.Namespace + "/" + meta.Name
+		}
+	}
+	return names
+}
+
+// addToMap is a helper function that adds values to a map m with key meta and context. The key is
+// added even when there are no values, so resources without the field are not reported as not found
+func addToMap(lock *sync.Mutex, m map[Meta]map[string][]any, meta Meta, context string, vals ...any) {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := m[meta]; !ok {
+		m[meta] = make(map[string][]any)
+	}
+	m[meta][context] = append(m[meta][context], vals...)
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
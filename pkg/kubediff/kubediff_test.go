@@ -0,0 +1,438 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// deployment returns the manifest of a deployment with a container app
+func deployment(namespace, name string, replicas int, image string) string {
+	return fmt.Sprintf(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+  namespace: %s
+spec:
+  replicas: %d
+  template:
+    spec:
+      containers:
+      - name: app
+        image: %s
+`, name, namespace, replicas, image)
+}
+
+// diffManifests runs differ with a source for each context reading its manifests
+func diffManifests(t *testing.T, differ *Differ, manifests map[string][]string) *Report {
+	t.Helper()
+	differ.Sources = make(map[string]Source, len(manifests))
+	for context, docs := range manifests {
+		src, err := ReadManifests(strings.NewReader(strings.Join(docs, "---\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		differ.Sources[context] = src
+	}
+	if differ.Selector.Resources == nil {
+		differ.Selector.Resources = []string{"deployments"}
+	}
+	report, err := differ.Diff(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// pairs returns the source and target contexts of the differences of a result, e.g. a>b
+func pairs(result ResourceResult) []string {
+	var p []string
+	for _, d := range result.Differences {
+		p = append(p, d.SourceContext+">"+d.TargetContext)
+	}
+	return p
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		contexts  []string
+		options   Options
+		manifests map[string][]string
+		want      map[string][]string
+		missing   map[string][]string
+	}{
+		{
+			name:     "equal resources are not reported",
+			contexts: []string{"a", "b"},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 2, "api:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+			},
+			want: map[string][]string{},
+		},
+		{
+			name:     "every pair of contexts is compared",
+			contexts: []string{"a", "b", "c"},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 1, "api:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+				"c": {deployment("web", "api", 3, "api:1")},
+			},
+			want: map[string][]string{"api": {"a>b", "a>c", "b>c"}},
+		},
+		{
+			name:     "contexts are only compared with the baseline",
+			contexts: []string{"a", "b", "c"},
+			options:  Options{Baseline: "b"},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 1, "api:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+				"c": {deployment("web", "api", 3, "api:1")},
+			},
+			want: map[string][]string{"api": {"b>a", "b>c"}},
+		},
+		{
+			name:     "consensus only reports the outliers against the majority",
+			contexts: []string{"a", "b", "c"},
+			options:  Options{Consensus: true},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 3, "api:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+				"c": {deployment("web", "api", 2, "api:1")},
+			},
+			want: map[string][]string{"api": {"b>a"}},
+		},
+		{
+			name:     "missing resources are reported",
+			contexts: []string{"a", "b"},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 2, "api:1"), deployment("web", "worker", 1, "worker:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+			},
+			want:    map[string][]string{"worker": nil},
+			missing: map[string][]string{"worker": {"b"}},
+		},
+		{
+			name:     "missing resources are ignored",
+			contexts: []string{"a", "b"},
+			options:  Options{IgnoreNonExistent: true},
+			manifests: map[string][]string{
+				"a": {deployment("web", "api", 2, "api:1"), deployment("web", "worker", 1, "worker:1")},
+				"b": {deployment("web", "api", 2, "api:1")},
+			},
+			want: map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differ := &Differ{Contexts: tt.contexts, Paths: []string{"spec.replicas"}, Options: tt.options}
+			report := diffManifests(t, differ, tt.manifests)
+			got := make(map[string][]string)
+			for _, result := range report.Resources {
+				got[result.Name] = pairs(result)
+				if !reflect.DeepEqual(result.Missing, tt.missing[result.Name]) {
+					t.Errorf("expected %s to be missing in %v, got %v", result.Name, tt.missing[result.Name], result.Missing)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected differences %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffConsensusGroupsOutliers(t *testing.T) {
+	spec := func(replicas, minReadySeconds, revisionHistoryLimit int) string {
+		return fmt.Sprintf(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: web
+spec:
+  replicas: %d
+  minReadySeconds: %d
+  revisionHistoryLimit: %d
+`, replicas, minReadySeconds, revisionHistoryLimit)
+	}
+	// the majority of each field is a different context, so sorting by source would split the outliers
+	differ := &Differ{Contexts: []string{"a", "b", "c", "d"}, Paths: []string{"spec"}, Options: Options{Consensus: true}}
+	report := diffManifests(t, differ, map[string][]string{
+		"a": {spec(1, 1, 1)},
+		"b": {spec(1, 2, 2)},
+		"c": {spec(1, 3, 2)},
+		"d": {spec(2, 2, 3)},
+	})
+	if len(report.Resources) != 1 {
+		t.Fatalf("expected 1 resource with differences, got %d", len(report.Resources))
+	}
+	want := []string{"b>a", "b>a", "b>c", "a>d", "b>d"}
+	if got := pairs(report.Resources[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected differences %v, got %v", want, got)
+	}
+}
+
+func TestDiffCompared(t *testing.T) {
+	differ := &Differ{Contexts: []string{"a", "b"}, Paths: []string{"spec.replicas", "spec.template.spec.containers[*].image"}}
+	report := diffManifests(t, differ, map[string][]string{
+		"a": {deployment("web", "api", 2, "api:1"), deployment("jobs", "worker", 1, "worker:1")},
+		"b": {deployment("web", "api", 2, "api:1"), deployment("web", "cron", 1, "cron:1")},
+	})
+	want := []Meta{
+		{Kind: "Deployment", Namespace: "jobs", Name: "worker"},
+		{Kind: "Deployment", Namespace: "web", Name: "api"},
+		{Kind: "Deployment", Namespace: "web", Name: "cron"},
+	}
+	if !reflect.DeepEqual(report.Compared, want) {
+		t.Errorf("expected compared resources %v, got %v", want, report.Compared)
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	manifests := map[string][]string{
+		"staging":    {deployment("web", "api", 1, "api:1")},
+		"production": {deployment("web", "api", 3, "api:1")},
+	}
+	tests := []struct {
+		name           string
+		rules          []Rule
+		wantDiffs      []ChangeType
+		wantSuppressed int
+	}{
+		{
+			name:      "without rules",
+			wantDiffs: []ChangeType{Changed},
+		},
+		{
+			name:           "ignored differences are suppressed",
+			rules:          []Rule{{Kind: "deployment", Name: "a*", Path: "spec.replicas", Action: RuleIgnore}},
+			wantSuppressed: 1,
+		},
+		{
+			name:      "rules of other resources do not apply",
+			rules:     []Rule{{Kind: "StatefulSet", Action: RuleIgnore}},
+			wantDiffs: []ChangeType{Changed},
+		},
+		{
+			name:           "allowed values are suppressed",
+			rules:          []Rule{{Action: RuleAllowedValues, Values: map[string][]any{"staging": {1, 2}, "production": {"3"}}}},
+			wantSuppressed: 1,
+		},
+		{
+			name:      "values that are not allowed are reported",
+			rules:     []Rule{{Action: RuleAllowedValues, Values: map[string][]any{"production": {5}}}},
+			wantDiffs: []ChangeType{Changed},
+		},
+		{
+			name:           "values that must differ are suppressed",
+			rules:          []Rule{{Path: "spec.replicas", Action: RuleMustDiffer}},
+			wantSuppressed: 1,
+		},
+		{
+			name:      "equal values that must differ are reported as unchanged",
+			rules:     []Rule{{Path: "spec.template.spec.containers[*].image", Action: RuleMustDiffer}},
+			wantDiffs: []ChangeType{Changed, Unchanged},
+		},
+		{
+			name: "the first rule that expects a difference suppresses it",
+			rules: []Rule{
+				{Action: RuleAllowedValues, Values: map[string][]any{"production": {5}}},
+				{Description: "scaled per environment", Action: RuleIgnore},
+			},
+			wantSuppressed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differ := &Differ{
+				Contexts: []string{"staging", "production"},
+				Paths:    []string{"spec.replicas", "spec.template.spec.containers[*].image"},
+				Options:  Options{Rules: tt.rules},
+			}
+			report := diffManifests(t, differ, manifests)
+			var got []ChangeType
+			for _, result := range report.Resources {
+				for _, d := range result.Differences {
+					got = append(got, d.Type)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantDiffs) {
+				t.Errorf("expected differences %v, got %v", tt.wantDiffs, got)
+			}
+			suppressed := 0
+			for _, result := range report.Suppressed {
+				suppressed += len(result.Differences)
+				for _, d := range result.Differences {
+					if d.Rule == "" {
+						t.Errorf("expected the rule of the suppressed difference %+v", d)
+					}
+				}
+			}
+			if suppressed != tt.wantSuppressed {
+				t.Errorf("expected %d suppressed differences, got %d", tt.wantSuppressed, suppressed)
+			}
+		})
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{name: "ignore", rule: Rule{Action: RuleIgnore}},
+		{name: "allowed values", rule: Rule{Action: RuleAllowedValues, Values: map[string][]any{"a": {1}}}},
+		{name: "allowed values without values", rule: Rule{Action: RuleAllowedValues}, wantErr: true},
+		{name: "values of other actions", rule: Rule{Action: RuleIgnore, Values: map[string][]any{"a": {1}}}, wantErr: true},
+		{name: "unknown action", rule: Rule{Action: "skip"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileRules([]Rule{tt.rule})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		parents bool
+		s       string
+		want    bool
+	}{
+		{pattern: "api-*", s: "api-web", want: true},
+		{pattern: "api-*", s: "web-api", want: false},
+		{pattern: "containers[name=app]", s: "containers[name=app]", want: true},
+		{pattern: "containers[name=app]", s: "containers[name=app].image", want: false},
+		{pattern: "containers[name=app]", parents: true, s: "containers[name=app].image", want: true},
+		{pattern: "containers[name=app]", parents: true, s: "containers[name=app2].image", want: false},
+		{pattern: "*.env[name=REGION]", parents: true, s: "[0].env[name=REGION].value", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if got := matchesPattern(globRegexp(tt.pattern, tt.parents), tt.s); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    Options
+		namespaces []string
+		manifests  map[string][]string
+		wantNames  map[string]string
+		wantErr    bool
+	}{
+		{
+			name:    "namespaces are mapped",
+			options: Options{NamespaceMappings: map[string]map[string]string{"staging": {"web-stg": "web"}}},
+			manifests: map[string][]string{
+				"staging":    {deployment("web-stg", "api", 1, "api:1")},
+				"production": {deployment("web", "api", 3, "api:1")},
+			},
+			wantNames: map[string]string{"staging": "web-stg/api"},
+		},
+		{
+			name:       "mapped namespaces are listed",
+			options:    Options{NamespaceMappings: map[string]map[string]string{"staging": {"web-stg": "web"}}},
+			namespaces: []string{"web"},
+			manifests: map[string][]string{
+				"staging":    {deployment("web-stg", "api", 1, "api:1")},
+				"production": {deployment("web", "api", 3, "api:1")},
+			},
+			wantNames: map[string]string{"staging": "web-stg/api"},
+		},
+		{
+			name:    "names are rewritten",
+			options: Options{NameRewrites: map[string][]NameRewrite{"staging": {{Pattern: "^(.*)-stg$", Replacement: "$1"}}}},
+			manifests: map[string][]string{
+				"staging":    {deployment("web", "api-stg", 1, "api:1")},
+				"production": {deployment("web", "api", 3, "api:1")},
+			},
+			wantNames: map[string]string{"staging": "web/api-stg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differ := &Differ{
+				Contexts: []string{"staging", "production"},
+				Selector: Selector{Namespaces: tt.namespaces},
+				Paths:    []string{"spec.replicas"},
+				Options:  tt.options,
+			}
+			report := diffManifests(t, differ, tt.manifests)
+			if len(report.Resources) != 1 {
+				t.Fatalf("expected the resources to be paired, got %+v", report.Resources)
+			}
+			result := report.Resources[0]
+			if result.Namespace != "web" || result.Name != "api" || len(result.Missing) > 0 || len(result.Differences) != 1 {
+				t.Errorf("expected a difference in web/api, got %+v", result)
+			}
+			if !reflect.DeepEqual(result.Names, tt.wantNames) {
+				t.Errorf("expected names %v, got %v", tt.wantNames, result.Names)
+			}
+		})
+	}
+}
+
+func TestMappingErrors(t *testing.T) {
+	contexts := []string{"staging", "production"}
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name:    "unknown context",
+			options: Options{NamespaceMappings: map[string]map[string]string{"dev": {"a": "b"}}},
+		},
+		{
+			name:    "namespaces mapped to the same namespace",
+			options: Options{NamespaceMappings: map[string]map[string]string{"staging": {"a": "c", "b": "c"}}},
+		},
+		{
+			name:    "invalid name rewrite",
+			options: Options{NameRewrites: map[string][]NameRewrite{"staging": {{Pattern: "("}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newMapping(contexts, tt.options); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestDiffPairingCollision(t *testing.T) {
+	differ := &Differ{
+		Contexts: []string{"staging", "production"},
+		Paths:    []string{"spec.replicas"},
+		Options:  Options{NameRewrites: map[string][]NameRewrite{"staging": {{Pattern: "-stg$", Replacement: ""}}}},
+		Sources:  map[string]Source{},
+	}
+	for context, docs := range map[string][]string{
+		"staging":    {deployment("web", "api", 1, "api:1"), deployment("web", "api-stg", 1, "api:1")},
+		"production": {deployment("web", "api", 1, "api:1")},
+	} {
+		src, err := ReadManifests(strings.NewReader(strings.Join(docs, "---\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		differ.Sources[context] = src
+	}
+	differ.Selector.Resources = []string{"deployments"}
+	if _, err := differ.Diff(context.Background()); err == nil || !strings.Contains(err.Error(), "both paired") {
+		t.Errorf("expected a pairing collision, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package kubediff
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NameRewrite rewrites the names of the resources of a context, so resources with different names in each
+// context are paired, e.g. the pattern ^(.*)-stg$ with the replacement $1 pairs api-stg with api
+type NameRewrite struct {
+	// Pattern is the regular expression matching the names
+	Pattern string
+	// Replacement replaces the match, $1 or ${1} is the first group of the pattern
+	Replacement string
+}
+
+// mapping maps the namespaces and names of the resources of each context to the namespaces and names they
+// are paired by
+type mapping struct {
+	// namespaces maps the namespaces of each context to the namespaces they are paired by
+	namespaces map[string]map[string]string
+	// contextNamespaces maps the namespaces that are paired by to the namespaces of each context
+	contextNamespaces map[string]map[string]string
+	names             map[string][]nameRewrite
+}
+
+type nameRewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// newMapping validates and compiles the namespace mappings and name rewrites of the options
+func newMapping(contexts []string, opts Options) (*mapping, error) {
+	m := &mapping{
+		namespaces:        opts.NamespaceMappings,
+		contextNamespaces: make(map[string]map[string]string),
+		names:             make(map[string][]nameRewrite),
+	}
+	for context, namespaces := range opts.NamespaceMappings {
+		if !stringInSlice(context, contexts) {
+			return nil, fmt.Errorf("namespace mapping of unknown context %s", context)
+		}
+		m.contextNamespaces[context] = make(map[string]string, len(namespaces))
+		for namespace, mapped := range namespaces {
+			if namespace == "" || mapped == "" {
+				return nil, fmt.Errorf("invalid namespace mapping of context %s, namespaces can not be empty", context)
+			}
+			if other, ok := m.contextNamespaces[context][mapped]; ok {
+				return nil, fmt.Errorf("namespaces %s and %s of context %s are both mapped to %s", other, namespace, context, mapped)
+			}
+			m.contextNamespaces[context][mapped] = namespace
+		}
+	}
+	for context, rewrites := range opts.NameRewrites {
+		if !stringInSlice(context, contexts) {
+			return nil, fmt.Errorf("name rewrite of unknown context %s", context)
+		}
+		for _, rewrite := range rewrites {
+			pattern, err := regexp.Compile(rewrite.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid name rewrite of context %s: %v", context, err)
+			}
+			m.names[context] = append(m.names[context], nameRewrite{pattern: pattern, replacement: rewrite.Replacement})
+		}
+	}
+	return m, nil
+}
+
+// contextNamespace returns the namespace of a context that is paired by namespace, e.g. payments-stg in
+// staging for payments. Every namespace is selected by the empty namespace
+func (m *mapping) contextNamespace(context, namespace string) string {
+	if mapped, ok := m.contextNamespaces[context][namespace]; ok && namespace != "" {
+		return mapped
+	}
+	return namespace
+}
+
+// meta returns the meta a resource of a context is paired by. The first name rewrite whose pattern matches the
+// name is applied
+func (m *mapping) meta(context string, meta Meta) Meta {
+	if namespace, ok := m.namespaces[context][meta.Namespace]; ok {
+		meta.Namespace = namespace
+	}
+	for _, rewrite := range m.names[context] {
+		if rewrite.pattern.MatchString(meta.Name) {
+			meta.Name = rewrite.pattern.ReplaceAllString(meta.Name, rewrite.replacement)
+			break
+		}
+	}
+	return meta
+}
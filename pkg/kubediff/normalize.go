@@ -0,0 +1,377 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	"github.com/eduardodbr/kubediff/internal/image"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// lastAppliedAnnotation is set by kubectl apply with the last applied manifest
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// headlessClusterIP is the cluster IP of headless services, which is set in manifests
+const headlessClusterIP = "None"
+
+// serverFields are the fields set by the API server or by controllers that are different in every cluster, such
+// as the revision of deployments. metadata.name and metadata.namespace are removed too, as objects are already
+// paired by them
+var serverFields = [][]string{
+	{"status"},
+	{"metadata", "name"},
+	{"metadata", "namespace"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", lastAppliedAnnotation},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+	{"metadata", "annotations", "deprecated.daemonset.template.generation"},
+}
+
+// fieldDefault is a field defaulted by the API server. Path elements ending in [] are lists whose elements
+// are defaulted
+type fieldDefault struct {
+	path  string
+	value any
+}
+
+// podSpecDefaults are the defaults of the fields of pod specs
+var podSpecDefaults = []fieldDefault{
+	{"dnsPolicy", "ClusterFirst"},
+	{"restartPolicy", "Always"},
+	{"schedulerName", "default-scheduler"},
+	{"terminationGracePeriodSeconds", 30},
+	{"volumes[].configMap.defaultMode", 420},
+	{"volumes[].secret.defaultMode", 420},
+	{"volumes[].projected.defaultMode", 420},
+}
+
+// containerDefaults are the defaults of the fields of containers and init containers
+var containerDefaults = []fieldDefault{
+	{"terminationMessagePath", "/dev/termination-log"},
+	{"terminationMessagePolicy", "File"},
+	{"ports[].protocol", "TCP"},
+}
+
+// probeDefaults are the defaults of the fields of liveness, readiness and startup probes
+var probeDefaults = []fieldDefault{
+	{"timeoutSeconds", 1},
+	{"periodSeconds", 10},
+	{"successThreshold", 1},
+	{"failureThreshold", 3},
+	{"httpGet.scheme", "HTTP"},
+}
+
+// kindDefaults are the defaults of the fields of each kind, besides those of their pod spec
+var kindDefaults = map[string][]fieldDefault{
+	"Deployment": {
+		{"spec.replicas", 1},
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.progressDeadlineSeconds", 600},
+		{"spec.strategy", map[string]any{"type": "RollingUpdate", "rollingUpdate": map[string]any{"maxSurge": "25%", "maxUnavailable": "25%"}}},
+	},
+	"StatefulSet": {
+		{"spec.replicas", 1},
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.podManagementPolicy", "OrderedReady"},
+		{"spec.updateStrategy", map[string]any{"type": "RollingUpdate", "rollingUpdate": map[string]any{"partition": 0}}},
+		{"spec.persistentVolumeClaimRetentionPolicy", map[string]any{"whenDeleted": "Retain", "whenScaled": "Retain"}},
+	},
+	"DaemonSet": {
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.updateStrategy", map[string]any{"type": "RollingUpdate", "rollingUpdate": map[string]any{"maxSurge": 0, "maxUnavailable": 1}}},
+	},
+	"Job": {
+		{"spec.backoffLimit", 6},
+		{"spec.completionMode", "NonIndexed"},
+		{"spec.completions", 1},
+		{"spec.parallelism", 1},
+		{"spec.suspend", false},
+	},
+	"CronJob": {
+		{"spec.concurrencyPolicy", "Allow"},
+		{"spec.failedJobsHistoryLimit", 1},
+		{"spec.successfulJobsHistoryLimit", 3},
+		{"spec.suspend", false},
+	},
+	"Service": {
+		{"spec.sessionAffinity", "None"},
+		{"spec.internalTrafficPolicy", "Cluster"},
+		{"spec.ipFamilyPolicy", "SingleStack"},
+		{"spec.ports[].protocol", "TCP"},
+	},
+}
+
+// podSpecPaths are the paths of the pod spec of each kind
+var podSpecPaths = map[string]string{
+	"Pod":         "spec",
+	"Deployment":  "spec.template.spec",
+	"StatefulSet": "spec.template.spec",
+	"DaemonSet":   "spec.template.spec",
+	"ReplicaSet":  "spec.template.spec",
+	"Job":         "spec.template.spec",
+	"CronJob":     "spec.jobTemplate.spec.template.spec",
+}
+
+// NormalizeObjects returns a function that normalizes whole objects, selected by the path $, so they can be
+// compared between clusters. The status, the fields set by the API server, such as metadata.uid,
+// metadata.managedFields, metadata.resourceVersion and the cluster IPs of services, the revision annotations and
+// the last applied configuration annotation are removed, and so are empty values. The requests and limits of
+// containers are written in their canonical form, so 1000m is equal to 1 and 1024Mi to 1Gi. If removeDefaults
+// is set, the fields whose value is the default set by the API server, e.g. dnsPolicy: ClusterFirst, are
+// removed too, so manifests are equal to the objects they create
+func NormalizeObjects(removeDefaults bool) ResolveFunc {
+	return func(_ context.Context, _ string, _ Source, meta Meta, vals []any) ([]any, error) {
+		normalized := make([]any, 0, len(vals))
+		for _, val := range vals {
+			obj, ok := deepCopy(val).(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("expected an object, found %T", val)
+			}
+			for _, field := range serverFields {
+				removeField(obj, field)
+			}
+			removeOwnerUIDs(obj)
+			removeClusterIPs(obj)
+			canonicalQuantities(obj, meta.Kind)
+			if removeDefaults {
+				removeDefaultedFields(obj, meta.Kind)
+			}
+			pruned, _ := prune(obj).(map[string]any)
+			if pruned == nil {
+				pruned = map[string]any{}
+			}
+			normalized = append(normalized, pruned)
+		}
+		return normalized, nil
+	}
+}
+
+// CompareObjects compares whole objects normalized by NormalizeObjects. The path of each change is the path
+// of the field, e.g. spec.template.spec.containers[name=app].image
+func CompareObjects(source, target []any) ([]Change, error) {
+	var s, t any
+	if len(source) > 0 {
+		s = source[0]
+	}
+	if len(target) > 0 {
+		t = target[0]
+	}
+	return diff.Compare(s, t), nil
+}
+
+// removeField removes a field of an object
+func removeField(obj map[string]any, path []string) {
+	for _, field := range path[:len(path)-1] {
+		next, ok := obj[field].(map[string]any)
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	delete(obj, path[len(path)-1])
+}
+
+// removeOwnerUIDs removes the uids of the owner references, which are different in every cluster
+func removeOwnerUIDs(obj map[string]any) {
+	metadata, _ := obj["metadata"].(map[string]any)
+	owners, _ := metadata["ownerReferences"].([]any)
+	for _, owner := range owners {
+		if o, ok := owner.(map[string]any); ok {
+			delete(o, "uid")
+		}
+	}
+}
+
+// removeClusterIPs removes the cluster IPs allocated to services, which are different in every cluster. The
+// cluster IP of headless services is kept, as it is set in their manifests
+func removeClusterIPs(obj map[string]any) {
+	spec, _ := obj["spec"].(map[string]any)
+	if spec == nil || spec["clusterIP"] == headlessClusterIP {
+		return
+	}
+	delete(spec, "clusterIP")
+	delete(spec, "clusterIPs")
+}
+
+// canonicalQuantities writes the requests and limits of the containers of an object of a kind in the canonical
+// form of their quantity, e.g. 1000m as 1. Values that are not quantities are kept
+func canonicalQuantities(obj map[string]any, kind string) {
+	podSpecPath, ok := podSpecPaths[kind]
+	if !ok {
+		return
+	}
+	for _, containers := range []string{"containers[]", "initContainers[]"} {
+		for _, list := range []string{"limits", "requests"} {
+			path := append(strings.Split(podSpecPath, "."), containers, "resources", list)
+			for _, val := range fieldValues(obj, path) {
+				quantities, ok := val.(map[string]any)
+				if !ok {
+					continue
+				}
+				for name, value := range quantities {
+					if q, err := resource.ParseQuantity(fmt.Sprint(value)); err == nil {
+						quantities[name] = q.String()
+					}
+				}
+			}
+		}
+	}
+}
+
+// removeDefaultedFields removes the fields of an object of a kind whose value is the default set by the API
+// server
+func removeDefaultedFields(obj map[string]any, kind string) {
+	for _, d := range kindDefaults[kind] {
+		removeDefault(obj, strings.Split(d.path, "."), d.value)
+	}
+	podSpecPath, ok := podSpecPaths[kind]
+	if !ok {
+		return
+	}
+	for _, podSpec := range fieldValues(obj, strings.Split(podSpecPath, ".")) {
+		spec, ok := podSpec.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, d := range podSpecDefaults {
+			removeDefault(spec, strings.Split(d.path, "."), d.value)
+		}
+		for _, containers := range []string{"containers[]", "initContainers[]"} {
+			for _, c := range fieldValues(spec, []string{containers}) {
+				container, ok := c.(map[string]any)
+				if !ok {
+					continue
+				}
+				removeDefaultPullPolicy(container)
+				for _, d := range containerDefaults {
+					removeDefault(container, strings.Split(d.path, "."), d.value)
+				}
+				for _, probe := range []string{"livenessProbe", "readinessProbe", "startupProbe"} {
+					for _, d := range probeDefaults {
+						removeDefault(container, append([]string{probe}, strings.Split(d.path, ".")...), d.value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// removeDefaultPullPolicy removes the image pull policy of a container if it is the default of its image:
+// Always for the latest tag and IfNotPresent for every other tag or digest
+func removeDefaultPullPolicy(container map[string]any) {
+	s, _ := container["image"].(string)
+	ref, err := image.Parse(s)
+	if err != nil {
+		return
+	}
+	policy := "IfNotPresent"
+	if ref.Tag == image.DefaultTag && ref.Digest == "" {
+		policy = "Always"
+	}
+	if container["imagePullPolicy"] == policy {
+		delete(container, "imagePullPolicy")
+	}
+}
+
+// removeDefault removes the field of path from obj, and from every element of the lists of the path, if its
+// value is value
+func removeDefault(obj map[string]any, path []string, value any) {
+	parents := fieldValues(obj, path[:len(path)-1])
+	for _, parent := range parents {
+		m, ok := parent.(map[string]any)
+		if !ok {
+			continue
+		}
+		field := path[len(path)-1]
+		if v, ok := m[field]; ok && len(diff.Compare(v, value)) == 0 {
+			delete(m, field)
+		}
+	}
+}
+
+// fieldValues returns the values of the path in obj, path elements ending in [] return every element of the list
+func fieldValues(obj any, path []string) []any {
+	values := []any{obj}
+	for _, field := range path {
+		name, isList := strings.CutSuffix(field, "[]")
+		var next []any
+		for _, v := range values {
+			m, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			child, ok := m[name]
+			if !ok {
+				continue
+			}
+			if !isList {
+				next = append(next, child)
+				continue
+			}
+			if list, ok := child.([]any); ok {
+				next = append(next, list...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// prune removes the nil values, empty maps and empty lists, which are equivalent to missing fields. Returns
+// nil if the value is empty
+func prune(val any) any {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		for key, value := range v {
+			if pruned := prune(value); pruned == nil {
+				delete(v, key)
+			} else {
+				v[key] = pruned
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		for i, value := range v {
+			if pruned := prune(value); pruned == nil {
+				// elements keep their index, empty elements are kept as empty maps
+				v[i] = map[string]any{}
+			} else {
+				v[i] = pruned
+			}
+		}
+	}
+	return val
+}
+
+// deepCopy copies the maps and lists of an unstructured value, so it can be modified
+func deepCopy(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = deepCopy(value)
+		}
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, value := range v {
+			l[i] = deepCopy(value)
+		}
+		return l
+	}
+	return val
+}
@@ -0,0 +1,174 @@
+package kubediff
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeObjects(t *testing.T) {
+	tests := []struct {
+		name           string
+		kind           string
+		removeDefaults bool
+		obj            map[string]any
+		want           map[string]any
+	}{
+		{
+			name: "server fields and empty values are removed",
+			kind: "ConfigMap",
+			obj: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]any{
+					"name":              "app",
+					"namespace":         "web",
+					"uid":               "1234",
+					"resourceVersion":   "42",
+					"creationTimestamp": "2024-01-01T00:00:00Z",
+					"managedFields":     []any{map[string]any{"manager": "kubectl"}},
+					"annotations":       map[string]any{lastAppliedAnnotation: "{}"},
+					"labels":            map[string]any{},
+				},
+				"data": map[string]any{"key": "value"},
+			},
+			want: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]any{"key": "value"},
+			},
+		},
+		{
+			name: "revision annotations are removed",
+			kind: "Deployment",
+			obj: map[string]any{
+				"metadata": map[string]any{"annotations": map[string]any{"deployment.kubernetes.io/revision": "7", "team": "web"}},
+			},
+			want: map[string]any{
+				"metadata": map[string]any{"annotations": map[string]any{"team": "web"}},
+			},
+		},
+		{
+			name: "cluster IPs are removed",
+			kind: "Service",
+			obj: map[string]any{"spec": map[string]any{
+				"clusterIP":  "10.0.0.1",
+				"clusterIPs": []any{"10.0.0.1"},
+				"ports":      []any{map[string]any{"port": int64(80)}},
+			}},
+			want: map[string]any{"spec": map[string]any{
+				"ports": []any{map[string]any{"port": int64(80)}},
+			}},
+		},
+		{
+			name: "the cluster IP of headless services is kept",
+			kind: "Service",
+			obj: map[string]any{"spec": map[string]any{
+				"clusterIP":  "None",
+				"clusterIPs": []any{"None"},
+			}},
+			want: map[string]any{"spec": map[string]any{
+				"clusterIP":  "None",
+				"clusterIPs": []any{"None"},
+			}},
+		},
+		{
+			name: "owner uids are removed",
+			kind: "ReplicaSet",
+			obj: map[string]any{
+				"metadata": map[string]any{"ownerReferences": []any{map[string]any{"kind": "Deployment", "name": "api", "uid": "1234"}}},
+			},
+			want: map[string]any{
+				"metadata": map[string]any{"ownerReferences": []any{map[string]any{"kind": "Deployment", "name": "api"}}},
+			},
+		},
+		{
+			name: "requests and limits are canonical",
+			kind: "Deployment",
+			obj: map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+				"containers": []any{map[string]any{"name": "app", "resources": map[string]any{
+					"limits":   map[string]any{"cpu": "1000m", "memory": "1024Mi"},
+					"requests": map[string]any{"cpu": int64(1), "memory": "0.5Gi"},
+				}}},
+				"initContainers": []any{map[string]any{"name": "init", "resources": map[string]any{
+					"limits": map[string]any{"cpu": "0.5", "example.com/gpu": "invalid quantity"},
+				}}},
+			}}}},
+			want: map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+				"containers": []any{map[string]any{"name": "app", "resources": map[string]any{
+					"limits":   map[string]any{"cpu": "1", "memory": "1Gi"},
+					"requests": map[string]any{"cpu": "1", "memory": "512Mi"},
+				}}},
+				"initContainers": []any{map[string]any{"name": "init", "resources": map[string]any{
+					"limits": map[string]any{"cpu": "500m", "example.com/gpu": "invalid quantity"},
+				}}},
+			}}}},
+		},
+		{
+			name: "defaults are kept",
+			kind: "Deployment",
+			obj:  map[string]any{"spec": map[string]any{"replicas": int64(1)}},
+			want: map[string]any{"spec": map[string]any{"replicas": int64(1)}},
+		},
+		{
+			name:           "defaults are removed",
+			kind:           "Deployment",
+			removeDefaults: true,
+			obj: map[string]any{"spec": map[string]any{
+				"replicas":             int64(1),
+				"revisionHistoryLimit": int64(5),
+				"strategy":             map[string]any{"type": "RollingUpdate", "rollingUpdate": map[string]any{"maxSurge": "25%", "maxUnavailable": "25%"}},
+				"template": map[string]any{"spec": map[string]any{
+					"dnsPolicy": "ClusterFirst",
+					"containers": []any{map[string]any{
+						"name":                   "app",
+						"image":                  "nginx:1.27",
+						"imagePullPolicy":        "IfNotPresent",
+						"terminationMessagePath": "/dev/termination-log",
+						"ports":                  []any{map[string]any{"containerPort": int64(80), "protocol": "TCP"}},
+						"readinessProbe":         map[string]any{"periodSeconds": int64(10), "timeoutSeconds": int64(5)},
+					}},
+				}},
+			}},
+			want: map[string]any{"spec": map[string]any{
+				"revisionHistoryLimit": int64(5),
+				"template": map[string]any{"spec": map[string]any{
+					"containers": []any{map[string]any{
+						"name":           "app",
+						"image":          "nginx:1.27",
+						"ports":          []any{map[string]any{"containerPort": int64(80)}},
+						"readinessProbe": map[string]any{"timeoutSeconds": int64(5)},
+					}},
+				}},
+			}},
+		},
+		{
+			name:           "the pull policy of latest images defaults to Always",
+			kind:           "Pod",
+			removeDefaults: true,
+			obj: map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "a", "image": "nginx", "imagePullPolicy": "Always"},
+				map[string]any{"name": "b", "image": "nginx:1.27", "imagePullPolicy": "Always"},
+			}}},
+			want: map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "a", "image": "nginx"},
+				map[string]any{"name": "b", "image": "nginx:1.27", "imagePullPolicy": "Always"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := deepCopy(tt.obj)
+			got, err := NormalizeObjects(tt.removeDefaults)(context.Background(), "a", nil, Meta{Kind: tt.kind}, []any{tt.obj})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, []any{tt.want}) {
+				t.Errorf("expected %v, got %v", tt.want, got[0])
+			}
+			if !reflect.DeepEqual(tt.obj, original) {
+				t.Errorf("expected the object not to be modified")
+			}
+		})
+	}
+}
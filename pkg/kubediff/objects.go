@@ -0,0 +1,58 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// objectCache lists the objects related to the compared resources, e.g. their pods, once per context,
+// kind and namespace
+type objectCache struct {
+	lock sync.Mutex
+	// cache has the unstructured content of the objects of a kind in a namespace
+	cache map[refCacheKey][]map[string]any
+}
+
+func newObjectCache() *objectCache {
+	return &objectCache{cache: make(map[refCacheKey][]map[string]any)}
+}
+
+// list returns the objects of a kind in a namespace sorted by name
+func (c *objectCache) list(ctx context.Context, context string, src Source, kind, namespace string) ([]map[string]any, error) {
+	key := refCacheKey{context: context, kind: kind, namespace: namespace}
+	c.lock.Lock()
+	objects, ok := c.cache[key]
+	c.lock.Unlock()
+	if ok {
+		return objects, nil
+	}
+	// each context is resolved by a single goroutine, so a namespace is never listed twice
+	err := src.Apply(ctx, kind, nil, namespace, func(item any, meta Meta) error {
+		content, err := fieldpath.ToUnstructured(item)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s in namespace %s: %v", kind, namespace, err)
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objectName(objects[i]) < objectName(objects[j])
+	})
+	c.lock.Lock()
+	c.cache[key] = objects
+	c.lock.Unlock()
+	return objects, nil
+}
+
+// objectName returns the name of an unstructured object
+func objectName(obj map[string]any) string {
+	return (&unstructured.Unstructured{Object: obj}).GetName()
+}
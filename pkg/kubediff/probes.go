@@ -0,0 +1,142 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ResolveProbes resolves containers to the settings of their liveness, readiness and startup probes and of their
+// postStart and preStop lifecycle hooks, e.g. {name: app, livenessProbe: {handler: httpGet HTTP :8080/healthz,
+// periodSeconds: 10, ...}}. The handler is described in a single line and omitted timing fields are set to the
+// default of the API server, so manifests can be compared with the objects of a cluster
+func ResolveProbes(_ context.Context, _ string, _ Source, _ Meta, vals []any) ([]any, error) {
+	resolved := make([]any, 0, len(vals))
+	for _, val := range vals {
+		container, err := toContainer(val)
+		if err != nil {
+			return nil, err
+		}
+		settings := map[string]any{"name": container.Name}
+		for name, probe := range map[string]*corev1.Probe{
+			"livenessProbe":  container.LivenessProbe,
+			"readinessProbe": container.ReadinessProbe,
+			"startupProbe":   container.StartupProbe,
+		} {
+			if probe != nil {
+				settings[name] = probeSettings(probe)
+			}
+		}
+		if container.Lifecycle != nil {
+			if container.Lifecycle.PostStart != nil {
+				settings["postStart"] = map[string]any{"handler": lifecycleHandler(container.Lifecycle.PostStart)}
+			}
+			if container.Lifecycle.PreStop != nil {
+				settings["preStop"] = map[string]any{"handler": lifecycleHandler(container.Lifecycle.PreStop)}
+			}
+		}
+		resolved = append(resolved, settings)
+	}
+	return resolved, nil
+}
+
+// CompareProbes compares the probes and lifecycle hooks resolved by ResolveProbes of the containers with the
+// same name in two contexts, e.g. containers[name=app].livenessProbe.timeoutSeconds
+func CompareProbes(source, target []any) ([]Change, error) {
+	return diff.Compare(map[string]any{"containers": source}, map[string]any{"containers": target}), nil
+}
+
+// probeSettings returns the handler and timing fields of a probe, with the defaults of the API server
+func probeSettings(probe *corev1.Probe) map[string]any {
+	settings := map[string]any{
+		"handler":             probeHandler(probe.ProbeHandler),
+		"initialDelaySeconds": int64(probe.InitialDelaySeconds),
+		"timeoutSeconds":      defaultSeconds(probe.TimeoutSeconds, 1),
+		"periodSeconds":       defaultSeconds(probe.PeriodSeconds, 10),
+		"successThreshold":    defaultSeconds(probe.SuccessThreshold, 1),
+		"failureThreshold":    defaultSeconds(probe.FailureThreshold, 3),
+	}
+	if probe.TerminationGracePeriodSeconds != nil {
+		settings["terminationGracePeriodSeconds"] = *probe.TerminationGracePeriodSeconds
+	}
+	return settings
+}
+
+func defaultSeconds(value, defaultValue int32) int64 {
+	if value == 0 {
+		return int64(defaultValue)
+	}
+	return int64(value)
+}
+
+// probeHandler describes the action of a probe, e.g. httpGet HTTP :8080/healthz or exec cat /tmp/healthy
+func probeHandler(handler corev1.ProbeHandler) string {
+	switch {
+	case handler.Exec != nil:
+		return execHandler(handler.Exec)
+	case handler.HTTPGet != nil:
+		return httpGetHandler(handler.HTTPGet)
+	case handler.TCPSocket != nil:
+		return fmt.Sprintf("tcpSocket %s:%s", handler.TCPSocket.Host, handler.TCPSocket.Port.String())
+	case handler.GRPC != nil:
+		if handler.GRPC.Service != nil && *handler.GRPC.Service != "" {
+			return fmt.Sprintf("grpc :%d service=%s", handler.GRPC.Port, *handler.GRPC.Service)
+		}
+		return fmt.Sprintf("grpc :%d", handler.GRPC.Port)
+	}
+	return "none"
+}
+
+// lifecycleHandler describes the action of a lifecycle hook, e.g. sleep 5s
+func lifecycleHandler(handler *corev1.LifecycleHandler) string {
+	switch {
+	case handler.Exec != nil:
+		return execHandler(handler.Exec)
+	case handler.HTTPGet != nil:
+		return httpGetHandler(handler.HTTPGet)
+	case handler.TCPSocket != nil:
+		return fmt.Sprintf("tcpSocket %s:%s", handler.TCPSocket.Host, handler.TCPSocket.Port.String())
+	case handler.Sleep != nil:
+		return fmt.Sprintf("sleep %ds", handler.Sleep.Seconds)
+	}
+	return "none"
+}
+
+// execHandler describes a command, arguments with spaces are quoted
+func execHandler(action *corev1.ExecAction) string {
+	args := make([]string, 0, len(action.Command))
+	for _, arg := range action.Command {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		args = append(args, arg)
+	}
+	return "exec " + strings.Join(args, " ")
+}
+
+// httpGetHandler describes an http request, e.g. httpGet HTTPS example.com:443/healthz X-Probe=1
+func httpGetHandler(action *corev1.HTTPGetAction) string {
+	scheme := action.Scheme
+	if scheme == "" {
+		scheme = corev1.URISchemeHTTP
+	}
+	path := action.Path
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "httpGet %s %s:%s%s", scheme, action.Host, action.Port.String(), path)
+	headers := make([]string, 0, len(action.HTTPHeaders))
+	for _, header := range action.HTTPHeaders {
+		headers = append(headers, fmt.Sprintf("%s=%s", header.Name, header.Value))
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		sb.WriteString(" " + header)
+	}
+	return sb.String()
+}
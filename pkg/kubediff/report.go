@@ -0,0 +1,186 @@
+package kubediff
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+)
+
+// Report is the result of a comparison
+type Report struct {
+	// Contexts are the compared contexts
+	Contexts []string `json:"contexts"`
+	// Resources are the resources with differences
+	Resources []ResourceResult `json:"resources"`
+	// Errors are the errors that prevented the comparison of some resources, see Options.ContinueOnError
+	Errors []ResourceError `json:"errors,omitempty"`
+	// Suppressed are the resources with differences expected by the rules, see Options.Rules. They are
+	// not part of Resources
+	Suppressed []ResourceResult `json:"suppressed,omitempty"`
+	// Compared are the resources found in any context, including those without differences, identified by
+	// the namespace and name they are paired by. They are not encoded, so the encoded report only has the
+	// resources with differences
+	Compared []Meta `json:"-"`
+}
+
+// ResourceResult is the result of comparing a resource between contexts
+type ResourceResult struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	// Values are the values found in the path, by context
+	Values map[string][]any `json:"values"`
+	// Names are the namespace and name of the resource in the contexts where it is mapped or renamed, see
+	// Options.NamespaceMappings and Options.NameRewrites
+	Names map[string]string `json:"names,omitempty"`
+	// Missing are the contexts where the resource was not found
+	Missing []string `json:"missing,omitempty"`
+	// Differences are the differences between each pair of contexts
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// Difference is a difference between the values of a resource in two contexts
+type Difference struct {
+	SourceContext string `json:"sourceContext"`
+	TargetContext string `json:"targetContext"`
+	Change
+	// Rule is the rule that suppressed the difference or, for unchanged values, that expects them to differ
+	Rule string `json:"rule,omitempty"`
+}
+
+// ResourceError is an error reading or comparing resources. Context is empty for comparison errors and
+// Name is empty when every resource of the type failed, e.g. when the cluster is not reachable
+type ResourceError struct {
+	Context   string `json:"context,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Resource  string `json:"resource"`
+	Name      string `json:"name,omitempty"`
+	Path      string `json:"path"`
+	Message   string `json:"message"`
+}
+
+func (e ResourceError) Error() string {
+	var sb strings.Builder
+	if e.Context != "" {
+		fmt.Fprintf(&sb, "context %s, ", e.Context)
+	}
+	if e.Namespace != "" {
+		fmt.Fprintf(&sb, "namespace %s, ", e.Namespace)
+	}
+	fmt.Fprintf(&sb, "resource %s", e.Resource)
+	if e.Name != "" {
+		fmt.Fprintf(&sb, " %s", e.Name)
+	}
+	fmt.Fprintf(&sb, ", path %s: %s", e.Path, e.Message)
+	return sb.String()
+}
+
+// joinErrors joins the errors into a single error
+func joinErrors(errs []ResourceError) error {
+	joined := make([]error, 0, len(errs))
+	for _, err := range errs {
+		joined = append(joined, err)
+	}
+	return errors.Join(joined...)
+}
+
+// sortErrors sorts the errors by context, in the order of contexts, and name
+func sortErrors(errs []ResourceError, contexts []string) {
+	order := make(map[string]int, len(contexts))
+	for i, context := range contexts {
+		order[context] = i
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Context != errs[j].Context {
+			return order[errs[i].Context] < order[errs[j].Context]
+		}
+		return errs[i].Name < errs[j].Name
+	})
+}
+
+// Change is a difference between two values. Its path is relative to the path of the resource result, list
+// elements matched by key are identified by their key (e.g. containers[name=app].image) and the remaining by
+// their index (e.g. args[0])
+type Change = diff.Difference
+
+// ChangeType is the type of a change
+type ChangeType = diff.Type
+
+// Types of changes
+const (
+	// Changed means the value exists in both contexts with different values
+	Changed = diff.Changed
+	// Removed means the value only exists in the source context
+	Removed = diff.Removed
+	// Added means the value only exists in the target context
+	Added = diff.Added
+	// Unchanged means the value is equal in both contexts but a rule expects it to differ, see RuleMustDiffer
+	Unchanged = diff.Unchanged
+)
+
+// HasDifferences reports if the resource is missing in a context or has different values
+func (r ResourceResult) HasDifferences() bool {
+	return len(r.Missing) > 0 || len(r.Differences) > 0
+}
+
+// String returns the kind, namespace and name of the resource, e.g. Deployment web/api
+func (r ResourceResult) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// sort sorts the resources and the suppressed resources by namespace, kind and name and the differences of
+// each resource by pair of contexts, in the order of the contexts. With consensus the pairs are sorted by
+// outlier first, so the differences of each outlier are grouped. Results of the same resource keep the order
+// of their paths and the differences of the same pair keep the order of the compare function
+func (r *Report) sort(consensus bool) {
+	sortResults(r.Resources, r.Contexts, consensus)
+	sortResults(r.Suppressed, r.Contexts, consensus)
+	sort.Slice(r.Compared, func(i, j int) bool {
+		a, b := r.Compared[i], r.Compared[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+}
+
+func sortResults(results []ResourceResult, contexts []string, consensus bool) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	order := make(map[string]int, len(contexts))
+	for i, context := range contexts {
+		order[context] = i
+	}
+	for _, result := range results {
+		sort.SliceStable(result.Differences, func(i, j int) bool {
+			a, b := result.Differences[i], result.Differences[j]
+			first, second := [2]string{a.SourceContext, a.TargetContext}, [2]string{b.SourceContext, b.TargetContext}
+			if consensus {
+				// the source is the majority, which can change between paths of the same outlier
+				first, second = [2]string{a.TargetContext, a.SourceContext}, [2]string{b.TargetContext, b.SourceContext}
+			}
+			if first[0] != second[0] {
+				return order[first[0]] < order[second[0]]
+			}
+			return order[first[1]] < order[second[1]]
+		})
+	}
+}
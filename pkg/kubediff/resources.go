@@ -0,0 +1,87 @@
+package kubediff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CompareContainerResources returns a function that compares the requests and limits of the containers with the
+// same name in two contexts. Quantities are compared by value, so 1000m is equal to 1 and 1Gi to 1024Mi. Values
+// whose relative difference is not greater than tolerance, a percentage, are considered equal
+func CompareContainerResources(tolerance float64) CompareFunc {
+	return func(sourceContainers, targetContainers []any) ([]Change, error) {
+		source, err := toContainers(sourceContainers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert source containers: %v", err)
+		}
+		target, err := toContainers(targetContainers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert target containers: %v", err)
+		}
+
+		var changes []Change
+		for _, sourceContainer := range source {
+			containerPath := fmt.Sprintf("containers[name=%s]", sourceContainer.Name)
+			targetContainer, ok := findContainer(target, sourceContainer.Name)
+			if !ok {
+				changes = append(changes, Change{Type: Removed, Path: containerPath})
+				continue
+			}
+			changes = append(changes, compareResourceList(containerPath+".resources.requests", sourceContainer.Resources.Requests, targetContainer.Resources.Requests, tolerance)...)
+			changes = append(changes, compareResourceList(containerPath+".resources.limits", sourceContainer.Resources.Limits, targetContainer.Resources.Limits, tolerance)...)
+		}
+		for _, targetContainer := range target {
+			if _, ok := findContainer(source, targetContainer.Name); !ok {
+				changes = append(changes, Change{Type: Added, Path: fmt.Sprintf("containers[name=%s]", targetContainer.Name)})
+			}
+		}
+		return changes, nil
+	}
+}
+
+// compareResourceList compares the quantities of every resource, e.g. cpu and memory, in sorted order
+func compareResourceList(path string, source, target corev1.ResourceList, tolerance float64) []Change {
+	names := make([]string, 0, len(source)+len(target))
+	for name := range source {
+		names = append(names, string(name))
+	}
+	for name := range target {
+		if _, ok := source[name]; !ok {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		s, inSource := source[corev1.ResourceName(name)]
+		t, inTarget := target[corev1.ResourceName(name)]
+		quantityPath := path + "." + name
+		switch {
+		case !inTarget:
+			changes = append(changes, Change{Type: Removed, Path: quantityPath, Source: s.String()})
+		case !inSource:
+			changes = append(changes, Change{Type: Added, Path: quantityPath, Target: t.String()})
+		case !equalQuantities(s, t, tolerance):
+			changes = append(changes, Change{Type: Changed, Path: quantityPath, Source: s.String(), Target: t.String()})
+		}
+	}
+	return changes
+}
+
+// equalQuantities checks if the relative difference between two quantities is not greater than tolerance
+func equalQuantities(source, target resource.Quantity, tolerance float64) bool {
+	if source.Cmp(target) == 0 {
+		return true
+	}
+	if tolerance <= 0 {
+		return false
+	}
+	s, t := source.AsApproximateFloat64(), target.AsApproximateFloat64()
+	max := math.Max(math.Abs(s), math.Abs(t))
+	return math.Abs(s-t)/max*100 <= tolerance
+}
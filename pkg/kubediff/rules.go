@@ -0,0 +1,228 @@
+package kubediff
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Actions of the rules
+const (
+	// RuleIgnore suppresses the differences
+	RuleIgnore = "ignore"
+	// RuleMustDiffer suppresses the differences and reports the pairs of contexts with equal values as
+	// unchanged, e.g. the ingress hosts of staging and production must not be the same
+	RuleMustDiffer = "mustDiffer"
+	// RuleAllowedValues suppresses the differences whose values are allowed in both contexts
+	RuleAllowedValues = "allowedValues"
+)
+
+// Rule declares an expected difference. A rule applies to the resources matching its kind, namespace, name
+// and path, where empty fields match everything. Namespace, name, path and field are glob patterns where *
+// matches any characters
+type Rule struct {
+	// Description describes why the difference is expected, it is reported with the suppressed differences
+	Description string `json:"description,omitempty"`
+	// Kind is the kind of the resources, e.g. Deployment
+	Kind string `json:"kind,omitempty"`
+	// Namespace is the namespace of the resources
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the resources, e.g. api-*
+	Name string `json:"name,omitempty"`
+	// Path is the compared path, e.g. spec.replicas or spec.template.spec.containers[*]
+	Path string `json:"path,omitempty"`
+	// Field is the path of the differences within the compared path, e.g. containers[*].env[name=REGION].
+	// It also matches the fields it contains
+	Field string `json:"field,omitempty"`
+	// Action is one of RuleIgnore, RuleMustDiffer or RuleAllowedValues
+	Action string `json:"action"`
+	// Values are the allowed values of each context for RuleAllowedValues. Contexts without values allow
+	// any value
+	Values map[string][]any `json:"values,omitempty"`
+}
+
+// RulesFile is a file with rules, e.g.
+//
+//	rules:
+//	- description: replicas are scaled per environment
+//	  kind: Deployment
+//	  path: spec.replicas
+//	  action: ignore
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules loads and validates the rules of a YAML or JSON rules file
+func LoadRules(file string) ([]Rule, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %v", err)
+	}
+	var rules RulesFile
+	if err := yaml.UnmarshalStrict(content, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %v", file, err)
+	}
+	if _, err := compileRules(rules.Rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %v", file, err)
+	}
+	return rules.Rules, nil
+}
+
+// rule is a rule with its glob patterns compiled. Patterns are nil when they match everything
+type rule struct {
+	Rule
+	label     string
+	namespace *regexp.Regexp
+	name      *regexp.Regexp
+	path      *regexp.Regexp
+	field     *regexp.Regexp
+}
+
+// compileRules validates the rules and compiles their patterns
+func compileRules(rules []Rule) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+	for i, r := range rules {
+		label := r.Description
+		if label == "" {
+			label = fmt.Sprintf("rule %d (%s)", i+1, r.Action)
+		}
+		switch r.Action {
+		case RuleIgnore, RuleMustDiffer:
+			if len(r.Values) > 0 {
+				return nil, fmt.Errorf("%s: values can only be used with action %s", label, RuleAllowedValues)
+			}
+		case RuleAllowedValues:
+			if len(r.Values) == 0 {
+				return nil, fmt.Errorf("%s: action %s requires values", label, RuleAllowedValues)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unsupported action %q, must be one of %s, %s or %s", label, r.Action, RuleIgnore, RuleMustDiffer, RuleAllowedValues)
+		}
+		compiled = append(compiled, rule{
+			Rule:      r,
+			label:     label,
+			namespace: globRegexp(r.Namespace, false),
+			name:      globRegexp(r.Name, false),
+			path:      globRegexp(r.Path, false),
+			field:     globRegexp(r.Field, true),
+		})
+	}
+	return compiled, nil
+}
+
+// globRegexp compiles a glob pattern where * matches any characters. When parents is set the pattern also
+// matches the paths it contains, e.g. containers[name=app] matches containers[name=app].image
+func globRegexp(pattern string, parents bool) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if parents {
+		return regexp.MustCompile(expr + `(?:$|[.\[])`)
+	}
+	return regexp.MustCompile(expr + "$")
+}
+
+func matchesPattern(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+// appliesTo checks if the rule applies to a path of a resource
+func (r rule) appliesTo(meta Meta, path string) bool {
+	return (r.Kind == "" || strings.EqualFold(r.Kind, meta.Kind)) &&
+		matchesPattern(r.namespace, meta.Namespace) &&
+		matchesPattern(r.name, meta.Name) &&
+		matchesPattern(r.path, path)
+}
+
+// expects checks if the rule expects a difference between two contexts
+func (r rule) expects(d Difference) bool {
+	if !matchesPattern(r.field, d.Path) {
+		return false
+	}
+	if r.Action != RuleAllowedValues {
+		return true
+	}
+	return r.allowed(d.SourceContext, d.Source) && r.allowed(d.TargetContext, d.Target)
+}
+
+// allowed checks if a value is allowed in a context. Values are compared by their string representation, so
+// 3 is equal to "3"
+func (r rule) allowed(context string, value any) bool {
+	values, ok := r.Values[context]
+	if !ok {
+		return true
+	}
+	if value == nil {
+		return false
+	}
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRules removes the differences expected by the rules from the result and returns them as suppressed. For
+// each pair of contexts without differences in the field of a RuleMustDiffer rule, an unchanged difference is
+// added to the result. changes returns the changes between a pair of contexts
+func applyRules(rules []rule, meta Meta, result *ResourceResult, pairs [][2]string, changes func(pair [2]string) ([]Change, error)) ([]Difference, error) {
+	var applicable []rule
+	for _, r := range rules {
+		if r.appliesTo(meta, result.Path) {
+			applicable = append(applicable, r)
+		}
+	}
+	if len(applicable) == 0 {
+		return nil, nil
+	}
+
+	var kept, suppressed []Difference
+	for _, d := range result.Differences {
+		expected := false
+		for _, r := range applicable {
+			if r.expects(d) {
+				d.Rule = r.label
+				suppressed = append(suppressed, d)
+				expected = true
+				break
+			}
+		}
+		if !expected {
+			kept = append(kept, d)
+		}
+	}
+
+	for _, r := range applicable {
+		if r.Action != RuleMustDiffer {
+			continue
+		}
+		for _, pair := range pairs {
+			pairChanges, err := changes(pair)
+			if err != nil {
+				return nil, err
+			}
+			differ := false
+			for _, change := range pairChanges {
+				if matchesPattern(r.field, change.Path) {
+					differ = true
+					break
+				}
+			}
+			if !differ {
+				kept = append(kept, Difference{
+					SourceContext: pair[0],
+					TargetContext: pair[1],
+					Change:        Change{Type: Unchanged, Path: r.Field},
+					Rule:          r.label,
+				})
+			}
+		}
+	}
+	result.Differences = kept
+	return suppressed, nil
+}
@@ -0,0 +1,162 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// podTemplateHashLabel is set by the deployment controller on the pods of a replicaset, whose name is the name
+// of the deployment followed by the hash
+const podTemplateHashLabel = "pod-template-hash"
+
+// runningImagesResolver resolves workloads to the image digests run by their pods in each context
+type runningImagesResolver struct {
+	objects *objectCache
+}
+
+// ResolveRunningImages returns a function that resolves the selector of a deployment, statefulset or daemonset,
+// found in spec.selector, to the image digests run by the containers of its pods, read from
+// status.containerStatuses[*].imageID and status.initContainerStatuses[*].imageID, e.g. {name: app, imageIDs:
+// [sha256:...]}. Containers whose pods run different digests have every digest. Containers that are not running
+// yet are ignored
+func ResolveRunningImages() ResolveFunc {
+	r := &runningImagesResolver{
+		objects: newObjectCache(),
+	}
+	return r.resolveSelectors
+}
+
+// CompareRunningImages compares the digests resolved by ResolveRunningImages of the containers with the same
+// name in two contexts. Containers running more than one digest are always reported, as the pods of the
+// workload run different images in the same context
+func CompareRunningImages(sourceContainers, targetContainers []any) ([]Change, error) {
+	source, target := runningImagesMap(sourceContainers), runningImagesMap(targetContainers)
+	names := make([]string, 0, len(source)+len(target))
+	for name := range source {
+		names = append(names, name)
+	}
+	for name := range target {
+		if _, ok := source[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		path := fmt.Sprintf("containers[name=%s].imageIDs", name)
+		s, inSource := source[name]
+		t, inTarget := target[name]
+		switch {
+		case !inTarget:
+			changes = append(changes, Change{Type: Removed, Path: path, Source: strings.Join(s, ",")})
+		case !inSource:
+			changes = append(changes, Change{Type: Added, Path: path, Target: strings.Join(t, ",")})
+		case len(s) > 1 || len(t) > 1 || s[0] != t[0]:
+			changes = append(changes, Change{Type: Changed, Path: path, Source: strings.Join(s, ","), Target: strings.Join(t, ",")})
+		}
+	}
+	return changes, nil
+}
+
+// runningImagesMap returns the digests of each container resolved by ResolveRunningImages
+func runningImagesMap(vals []any) map[string][]string {
+	containers := make(map[string][]string)
+	for _, val := range vals {
+		container, ok := val.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(container["name"])
+		ids, _ := container["imageIDs"].([]any)
+		for _, id := range ids {
+			containers[name] = append(containers[name], fmt.Sprint(id))
+		}
+	}
+	return containers
+}
+
+func (r *runningImagesResolver) resolveSelectors(ctx context.Context, context string, src Source, meta Meta, vals []any) ([]any, error) {
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	pods, err := r.objects.list(ctx, context, src, "pod", meta.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	// digests run by each container
+	digests := make(map[string]map[string]bool)
+	for _, content := range pods {
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &pod); err != nil {
+			return nil, fmt.Errorf("failed to convert pod %s/%s: %v", meta.Namespace, objectName(content), err)
+		}
+		matches, err := selects(vals[0], pod.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of %s %s/%s: %v", meta.Kind, meta.Namespace, meta.Name, err)
+		}
+		if !matches || !ownedBy(&pod, meta) {
+			continue
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.ImageID == "" {
+				continue
+			}
+			if digests[status.Name] == nil {
+				digests[status.Name] = make(map[string]bool)
+			}
+			digests[status.Name][imageDigest(status.ImageID)] = true
+		}
+	}
+
+	names := make([]string, 0, len(digests))
+	for name := range digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resolved := make([]any, 0, len(names))
+	for _, name := range names {
+		ids := make([]string, 0, len(digests[name]))
+		for id := range digests[name] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		imageIDs := make([]any, 0, len(ids))
+		for _, id := range ids {
+			imageIDs = append(imageIDs, id)
+		}
+		resolved = append(resolved, map[string]any{"name": name, "imageIDs": imageIDs})
+	}
+	return resolved, nil
+}
+
+// ownedBy checks if a pod is owned by a workload. Pods of deployments are owned by one of its replicasets
+func ownedBy(pod *corev1.Pod, meta Meta) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false
+	}
+	if meta.Kind == "Deployment" {
+		hash, ok := pod.Labels[podTemplateHashLabel]
+		return ok && owner.Kind == "ReplicaSet" && owner.Name == meta.Name+"-"+hash
+	}
+	return owner.Kind == meta.Kind && owner.Name == meta.Name
+}
+
+// imageDigest returns the digest of an image ID, e.g. docker-pullable://nginx@sha256:... is sha256:...
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		return imageID[i+3:]
+	}
+	return imageID
+}
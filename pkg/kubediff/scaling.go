@@ -0,0 +1,167 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eduardodbr/kubediff/internal/diff"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// defaultHPAMetrics are the metrics the API server sets on autoscaling/v2 horizontalpodautoscalers without metrics
+var defaultHPAMetrics = []any{cpuUtilizationMetric(int64(80))}
+
+// scalingResolver resolves the replicas, horizontalpodautoscalers and poddisruptionbudgets of workloads with
+// the objects found in each context
+type scalingResolver struct {
+	objects *objectCache
+}
+
+// ResolveScaling returns a function that resolves the spec of a deployment or statefulset to the settings that
+// decide how many pods it runs: the replicas, the horizontalpodautoscalers targeting it and the
+// poddisruptionbudgets selecting its pods. The replicas are omitted when a horizontalpodautoscaler targets the
+// workload, as they are set by the autoscaler. Omitted fields set to their default by the API server are
+// defaulted, so manifests can be compared with the objects of a cluster
+func ResolveScaling() ResolveFunc {
+	r := &scalingResolver{
+		objects: newObjectCache(),
+	}
+	return r.resolveSpecs
+}
+
+// CompareScaling compares the settings resolved by ResolveScaling in two contexts. Paths are relative to the
+// settings, e.g. horizontalPodAutoscalers[name=api].maxReplicas
+func CompareScaling(source, target []any) ([]Change, error) {
+	return diff.Compare(toData(source), toData(target)), nil
+}
+
+func (r *scalingResolver) resolveSpecs(ctx context.Context, context string, src Source, meta Meta, vals []any) ([]any, error) {
+	resolved := make([]any, 0, len(vals))
+	for _, val := range vals {
+		spec, ok := val.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("spec of %s %s is not an object", meta.Kind, meta.Name)
+		}
+		scaling, err := r.resolveSpec(ctx, context, src, meta, spec)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, scaling)
+	}
+	return resolved, nil
+}
+
+func (r *scalingResolver) resolveSpec(ctx context.Context, context string, src Source, meta Meta, spec map[string]any) (map[string]any, error) {
+	scaling := make(map[string]any)
+
+	hpas, err := r.objects.list(ctx, context, src, "horizontalpodautoscaler", meta.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var autoscalers []any
+	for _, hpa := range hpas {
+		hpaSpec, _ := hpa["spec"].(map[string]any)
+		target, _ := hpaSpec["scaleTargetRef"].(map[string]any)
+		if target["kind"] != meta.Kind || target["name"] != meta.Name {
+			continue
+		}
+		autoscalers = append(autoscalers, autoscalerSettings(hpa))
+	}
+	if len(autoscalers) > 0 {
+		scaling["horizontalPodAutoscalers"] = autoscalers
+	} else {
+		replicas, ok := spec["replicas"]
+		if !ok {
+			replicas = int64(1)
+		}
+		scaling["replicas"] = replicas
+	}
+
+	pdbs, err := r.objects.list(ctx, context, src, "poddisruptionbudget", meta.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	podLabels, _, err := unstructured.NestedStringMap(spec, "template", "metadata", "labels")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod labels of %s %s/%s: %v", meta.Kind, meta.Namespace, meta.Name, err)
+	}
+	var budgets []any
+	for _, pdb := range pdbs {
+		pdbSpec, _ := pdb["spec"].(map[string]any)
+		matches, err := selects(pdbSpec["selector"], podLabels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of poddisruptionbudget %s/%s: %v", meta.Namespace, objectName(pdb), err)
+		}
+		if !matches {
+			continue
+		}
+		budget := map[string]any{"name": objectName(pdb)}
+		for _, field := range []string{"minAvailable", "maxUnavailable"} {
+			if value, ok := pdbSpec[field]; ok {
+				budget[field] = value
+			}
+		}
+		budgets = append(budgets, budget)
+	}
+	if len(budgets) > 0 {
+		scaling["podDisruptionBudgets"] = budgets
+	}
+	return scaling, nil
+}
+
+// autoscalerSettings returns the name, replicas and metrics of a horizontalpodautoscaler. The target cpu
+// utilization of autoscaling/v1 is converted to an autoscaling/v2 metric
+func autoscalerSettings(hpa map[string]any) map[string]any {
+	spec, _ := hpa["spec"].(map[string]any)
+	settings := map[string]any{
+		"name":        objectName(hpa),
+		"minReplicas": int64(1),
+		"maxReplicas": spec["maxReplicas"],
+	}
+	if minReplicas, ok := spec["minReplicas"]; ok {
+		settings["minReplicas"] = minReplicas
+	}
+	switch {
+	case spec["metrics"] != nil:
+		settings["metrics"] = spec["metrics"]
+	case spec["targetCPUUtilizationPercentage"] != nil:
+		settings["metrics"] = []any{cpuUtilizationMetric(spec["targetCPUUtilizationPercentage"])}
+	default:
+		settings["metrics"] = defaultHPAMetrics
+	}
+	return settings
+}
+
+// cpuUtilizationMetric returns an autoscaling/v2 metric with a target average cpu utilization
+func cpuUtilizationMetric(utilization any) map[string]any {
+	return map[string]any{
+		"type": "Resource",
+		"resource": map[string]any{
+			"name": "cpu",
+			"target": map[string]any{
+				"type":               "Utilization",
+				"averageUtilization": utilization,
+			},
+		},
+	}
+}
+
+// selects checks if a label selector selects the pods with podLabels. A missing selector selects no pods
+func selects(selector any, podLabels map[string]string) (bool, error) {
+	content, ok := selector.(map[string]any)
+	if !ok {
+		return false, nil
+	}
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &labelSelector); err != nil {
+		return false, err
+	}
+	s, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(labels.Set(podLabels)), nil
+}
@@ -0,0 +1,74 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/eduardodbr/kubediff/internal/fieldpath"
+	"github.com/eduardodbr/kubediff/internal/secret"
+	"github.com/eduardodbr/kubediff/internal/source"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// WriteSnapshot captures the objects selected by selector from the source of a context into a snapshot archive
+// written to w, a gzip compressed tar with a YAML file for each object. Secret values are replaced by their
+// fingerprints, the SHA-256 of the value or, if hmacKey is set, the HMAC-SHA256, so compare the snapshot with
+// the same key. The snapshot can be loaded with LoadSnapshot
+func WriteSnapshot(ctx context.Context, w io.Writer, context string, src Source, selector Selector, hmacKey []byte) error {
+	if len(selector.Resources) == 0 {
+		return fmt.Errorf("at least one resource is required")
+	}
+	namespaces := selector.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	fingerprinter := secret.NewFingerprinter(hmacKey)
+	var objects []map[string]any
+	// the same object may be selected more than once, e.g. by deploy and deployments
+	seen := make(map[string]bool)
+	for _, namespace := range namespaces {
+		for _, resourceType := range selector.Resources {
+			err := src.Apply(ctx, resourceType, selector.Labels, namespace, func(item any, meta Meta) error {
+				var content map[string]any
+				var err error
+				if meta.Kind == secret.Kind {
+					content, err = fingerprinter.Redact(item)
+				} else {
+					content, err = fieldpath.ToUnstructured(item)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read %s %s/%s: %v", meta.Kind, meta.Namespace, meta.Name, err)
+				}
+				obj := &unstructured.Unstructured{Object: content}
+				key := fmt.Sprintf("%s/%s/%s", obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName())
+				if !seen[key] {
+					seen[key] = true
+					objects = append(objects, content)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list %s: %v", resourceType, err)
+			}
+		}
+	}
+	metadata := source.SnapshotMetadata{
+		Context:    context,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+		Resources:  selector.Resources,
+		Namespaces: selector.Namespaces,
+		Labels:     selector.Labels,
+	}
+	return source.WriteSnapshot(w, metadata, objects)
+}
+
+// LoadSnapshot creates a source that reads the objects of a snapshot archive written by WriteSnapshot
+func LoadSnapshot(path string) (Source, error) {
+	m, _, err := source.LoadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,79 @@
+package kubediff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	k8s "github.com/eduardodbr/kubediff/internal/kubernetes"
+	"github.com/eduardodbr/kubediff/internal/resource"
+	"github.com/eduardodbr/kubediff/internal/source"
+)
+
+// Source provides the objects of a context. Objects are passed to the function applied by the source as
+// typed objects or as unstructured content
+type Source = source.Source
+
+// Meta identifies an object
+type Meta = resource.Meta
+
+// NewSource creates a source from its description in the format <name>=<type>:<location>, where type is one
+// of ctx, file, dir, stdin or snapshot, e.g. staging=ctx:staging or desired=dir:./manifests. Uses the kubeconfig
+// file provided by `kubeconfig` for ctx sources. Returns the name of the source
+func NewSource(description, kubeconfig string) (string, Source, error) {
+	spec, err := source.ParseSpec(description)
+	if err != nil {
+		return "", nil, err
+	}
+	src, err := source.New(spec, kubeconfig)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create source %s: %v", spec.Name, err)
+	}
+	return spec.Name, src, nil
+}
+
+// NewContextSource creates a source that reads the objects from a context of the kubeconfig file provided
+// by `kubeconfig`
+func NewContextSource(kubeconfig, context string) (Source, error) {
+	client, err := k8s.CreateClient(kubeconfig, context)
+	if err != nil {
+		return nil, err
+	}
+	return source.NewCluster(client), nil
+}
+
+// LoadManifests creates a source that reads the objects from the YAML or JSON manifests of a file or of the
+// .yaml, .yml and .json files of a directory and its subdirectories
+func LoadManifests(path string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifests: %v", err)
+	}
+	m, err := source.LoadManifests(path, info.IsDir())
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadManifests creates a source that reads the objects from a stream of YAML or JSON documents
+func ReadManifests(r io.Reader) (Source, error) {
+	m, err := source.ReadManifests(r, "stream")
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ServerVersion returns the Kubernetes version of the cluster of a source, e.g. v1.31.3, or an empty string if
+// the source does not read from a cluster, e.g. manifests. The request is cancelled with ctx
+func ServerVersion(ctx context.Context, src Source) (string, error) {
+	cluster, ok := src.(interface {
+		ServerVersion(ctx context.Context) (string, error)
+	})
+	if !ok {
+		return "", nil
+	}
+	return cluster.ServerVersion(ctx)
+}